@@ -4,6 +4,10 @@ Copyright © 2023 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"log"
+	"time"
+
+	"c8backup/pkg/kube"
 	"c8backup/pkg/runner"
 	"github.com/spf13/cobra"
 )
@@ -15,24 +19,46 @@ var tasklistURL string
 var optimizeURL string
 var elasticURL string
 var elasticSnapshotRepositoryName string
+var ignoreVersionCheck bool
+var maxExportPause time.Duration
+var hardPause bool
 
 // backupCmd represents the backup command
 var backupCmd = &cobra.Command{
 	Use:   "backup",
 	Short: "backup C8 platform",
 	Long:  `Backup Camunda 8 Platform`,
-	Run: func(cmd *cobra.Command, args []string) {
-		backupDefinition := runner.NewBackupDefinitionBuilder().
+	Run: withLock(func(cmd *cobra.Command, args []string) error {
+		// Kubernetes is needed for the registered components and the manifest of the backup
+		builder := runner.NewBackupDefinitionBuilder()
+		kubeClient, err := kube.NewClientset(kubeconfig, kubeContext)
+		if err != nil {
+			if len(resourceSelectors()) > 0 || hasDatabase() {
+				return err
+			}
+			log.Println("no kubernetes access, the manifest of the backup is not written:", err)
+		} else {
+			clients, err := componentClients(kubeClient)
+			if err != nil {
+				return err
+			}
+			builder = builder.Components(clients).Kube(kubeClient, namespace)
+		}
+		backupDefinition := builder.
 			Operate(operateURL).
 			Tasklist(tasklistURL).
 			Optimize(optimizeURL).
 			Elastic(elasticURL, elasticSnapshotRepositoryName).
 			Zeebe(zeebeURL).
+			ZeebeStatefulSet(zeebeStatefulSet).
 			ZeebeIndexPrefix(zeebeIndexPrefix).
+			IgnoreVersionCheck(ignoreVersionCheck).
+			MaxExportPause(maxExportPause).
+			HardPause(hardPause).
 			Build()
 
-		runner.DoBackup(backupDefinition)
-	},
+		return runner.DoBackup(cmd.Context(), backupDefinition)
+	}),
 }
 
 func init() {
@@ -46,5 +72,11 @@ func init() {
 
 	backupCmd.Flags().StringVar(&zeebeURL, "zeebe", "", "Pass in the url to the zeebe mgmt endpoint")
 	backupCmd.Flags().StringVar(&zeebeIndexPrefix, "zeebe-index-prefix", "zeebe-record*", "Pass in the zeebe elasticsearch record prefix. Default: 'zeebe-record*'")
+	backupCmd.Flags().StringVar(&zeebeStatefulSet, "zeebe-statefulset", "", "Name of the zeebe broker statefulset the cluster size is recorded from. Default: detected")
+	backupCmd.Flags().DurationVar(&maxExportPause, "max-export-pause", runner.DefaultMaxExportPause, "Resume zeebe exporting after this long even if the zeebe backup is not done, the backup fails then")
+	backupCmd.Flags().BoolVar(&hardPause, "hard-pause", false, "Pause zeebe exporting hard even if the brokers support a soft pause")
+	addComponentFlags(backupCmd)
+	addBackupStoreFlags(backupCmd)
+	backupCmd.Flags().BoolVar(&ignoreVersionCheck, "ignore-version-check", false, "Back up even if the versions of the components don't fit together")
 	backupCmd.MarkFlagsRequiredTogether("elastic", "elastic-repository")
 }
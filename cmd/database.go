@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/backup-client/postgres"
+	"c8backup/pkg/backup-client/resources"
+	"c8backup/pkg/restore"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+// componentDatabases and componentSelectors hold the flags of the registered components by name, shared by the
+// commands like the other flag variables
+var componentDatabases = map[string]*postgres.Config{}
+var componentSelectors = map[string]*string{}
+var dumpImage string
+var dumpStoreImage string
+
+// addComponentFlags adds the flags of the registered components: --<name>-db and friends for the PostgreSQL
+// databases backed up with pg_dump, --<name>-selector for the kept Secrets and ConfigMaps.
+func addComponentFlags(cmd *cobra.Command) {
+	for _, componentType := range restore.ComponentTypes() {
+		name := componentType.Name
+		if componentType.Database != nil {
+			database := componentDatabases[name]
+			if database == nil {
+				defaults := *componentType.Database
+				database = &defaults
+				componentDatabases[name] = database
+			}
+			cmd.Flags().StringVar(&database.Address, name+"-db", "", fmt.Sprintf("host[:port] of the postgresql database of %s", name))
+			cmd.Flags().StringVar(&database.Database, name+"-db-name", database.Database, fmt.Sprintf("Name of the %s database", name))
+			cmd.Flags().StringVar(&database.User, name+"-db-user", database.User, fmt.Sprintf("User of the %s database", name))
+			cmd.Flags().StringVar(&database.PasswordSecret, name+"-db-secret", "", fmt.Sprintf("Secret with the password of the %s database user", name))
+			cmd.Flags().StringVar(&database.PasswordKey, name+"-db-secret-key", database.PasswordKey, fmt.Sprintf("Key of the password in the %s database secret", name))
+		}
+		if componentType.Resources {
+			selector := componentSelectors[name]
+			if selector == nil {
+				selector = new(string)
+				componentSelectors[name] = selector
+			}
+			cmd.Flags().StringVar(selector, name+"-selector", "", fmt.Sprintf("Label selector of the secrets and configmaps of %s to keep", name))
+		}
+	}
+	cmd.Flags().StringVar(&dumpImage, "dump-image", postgres.DefaultImage, "Image running pg_dump and pg_restore")
+	cmd.Flags().StringVar(&dumpStoreImage, "dump-store-image", postgres.DefaultStoreImage, "Image running rclone, which copies the database dumps to and from the zeebe backup store")
+}
+
+// databases returns the configured databases. Their dumps are kept in the Zeebe backup store, given with
+// --backup-store or detected from the Zeebe StatefulSet of the namespace.
+func databases(kubeClient kubernetes.Interface) ([]postgres.Config, error) {
+	var configs []postgres.Config
+	for _, componentType := range restore.ComponentTypes() {
+		database := componentDatabases[componentType.Name]
+		if database == nil || database.Address == "" {
+			continue
+		}
+		if database.PasswordSecret == "" {
+			return nil, fmt.Errorf("--%s-db needs --%s-db-secret", componentType.Name, componentType.Name)
+		}
+		config := *database
+		config.Name = componentType.Name
+		config.Image = dumpImage
+		config.StoreImage = dumpStoreImage
+		configs = append(configs, config)
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	store, err := restore.NewBackupStore(backupStoreConfig)
+	if err == nil && store == nil {
+		store, err = restore.DetectZeebeBackupStore(context.Background(), kubeClient, namespace, zeebeStatefulSet)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("the database dumps are kept in the zeebe backup store: %w", err)
+	}
+	for i := range configs {
+		configs[i].Store = store
+	}
+	return configs, nil
+}
+
+// resourceSelectors returns the configured selectors of the kept resources by component.
+func resourceSelectors() map[string]string {
+	selectors := map[string]string{}
+	for name, selector := range componentSelectors {
+		if *selector != "" {
+			selectors[name] = *selector
+		}
+	}
+	return selectors
+}
+
+// componentClients returns the backup clients of the configured registered components, in registration order.
+func componentClients(kubeClient kubernetes.Interface) ([]component.Component, error) {
+	databaseConfigs, err := databases(kubeClient)
+	if err != nil {
+		return nil, err
+	}
+	selectors := resourceSelectors()
+	var clients []component.Component
+	for _, componentType := range restore.ComponentTypes() {
+		for _, database := range databaseConfigs {
+			if database.Name == componentType.Name {
+				clients = append(clients, postgres.NewClient(kubeClient, namespace, database))
+			}
+		}
+		if selector := selectors[componentType.Name]; selector != "" {
+			clients = append(clients, resources.NewClient(kubeClient, namespace, componentType.Name, selector))
+		}
+	}
+	return clients, nil
+}
+
+func hasDatabase() bool {
+	for _, database := range componentDatabases {
+		if database.Address != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"c8backup/pkg/kube"
+	"c8backup/pkg/lock"
+	"github.com/spf13/cobra"
+)
+
+var forceUnlock bool
+var lockTTL time.Duration
+
+// newLocker prefers a Lease in the target namespace and falls back to a local lock file if the cluster is not reachable.
+func newLocker() lock.Locker {
+	identity := lock.Identity()
+	kubeClient, err := kube.NewClientset(kubeconfig, kubeContext)
+	if err == nil {
+		_, err = kubeClient.Discovery().ServerVersion()
+	}
+	if err != nil {
+		log.Println("no kubernetes access, falling back to a local lock file:", err)
+		return lock.NewFileLock(namespace, identity, lockTTL)
+	}
+	return lock.NewLeaseLock(kubeClient, namespace, identity, lockTTL)
+}
+
+// withLock wraps the Run of a command so that only one backup, restore, delete or prune runs at a time. SIGINT and
+// SIGTERM cancel the context of the command instead of exiting, so that it can clean up and the lock is released. A
+// second signal exits right away. Losing the lock cancels the context as well.
+func withLock(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) {
+	return func(cmd *cobra.Command, args []string) {
+		if lockTTL < lock.MinTTL {
+			log.Fatalf("invalid argument %s for --lock-ttl, it has to be at least %s\n", lockTTL, lock.MinTTL)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-ctx.Done()
+			stop()
+		}()
+		locker := newLocker()
+		if forceUnlock {
+			log.Println("force unlocking")
+			err := locker.ForceUnlock(ctx)
+			if err != nil {
+				log.Fatalln("unable to force unlock", err)
+			}
+		}
+		err := locker.Lock(ctx)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		// Once the lock is lost another run may start, so the command is stopped like on a signal
+		runCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-locker.Lost():
+				log.Println("🚨 the lock was lost, stopping")
+				cancel()
+			case <-runCtx.Done():
+			}
+		}()
+		cmd.SetContext(runCtx)
+		err = run(cmd, args)
+		// The context may be cancelled already
+		unlockErr := locker.Unlock(context.Background())
+		if unlockErr != nil {
+			log.Println("unable to release lock", unlockErr)
+		}
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
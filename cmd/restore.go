@@ -16,39 +16,259 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
-	"log"
+	"os"
+	"strings"
+	"time"
 
+	"c8backup/pkg/kube"
 	"c8backup/pkg/restore"
 	"github.com/spf13/cobra"
 )
 
 var backupID int64
-var namespace string
+var dryRun bool
+var planFile string
+var applyPlanFile string
+var restoreComponents []string
+var indexPrefixes map[string]string
+var wipeAll bool
+var healthTimeout time.Duration
+var appSelector string
+var argoCDNamespace string
+var freshRestore bool
+var sourceContext string
+var sourceNamespace string
+var backupStoreConfig restore.BackupStoreConfig
+var jobTimeout time.Duration
+var zeebeStatefulSet string
+var jobNodeSelector map[string]string
+var jobTolerations []string
+var jobRunAsUser int64
+var jobFSGroup int64
+var jobRequests map[string]string
+var jobLimits map[string]string
+var helperImage string
+var imageRegistry string
+var imagePullSecrets []string
+var wipeWithZeebeImage bool
+var resumeRestore bool
+var restoreBefore string
+var restoreLatest bool
+var assumeYes bool
+var ignoreManifest bool
+var abortRestore bool
 
 // restoreCmd represents the restore command
 var restoreCmd = &cobra.Command{
 	Use:   "restore",
 	Short: "A brief description of your command",
-	Run: func(cmd *cobra.Command, args []string) {
+	Run: withLock(func(cmd *cobra.Command, args []string) error {
 		fmt.Println("restore called")
 		// Todo: Refactor this with something easier :)
-		if backupID == 0 {
-			log.Fatal("invalid backup id", backupID)
+		if backupID == 0 && applyPlanFile == "" && !resumeRestore && !abortRestore && restoreBefore == "" && !restoreLatest {
+			return fmt.Errorf("invalid backup id %d", backupID)
 		}
-		restore.Restore(namespace, backupID, elasticURL, operateURL, tasklistURL, optimizeURL, elasticSnapshotRepositoryName)
-	},
+		components, err := restore.ParseComponents(restoreComponents)
+		if err != nil {
+			return err
+		}
+		backupStore, err := restore.NewBackupStore(backupStoreConfig)
+		if err != nil {
+			return err
+		}
+		jobOverrides, err := parseJobOverrides(cmd)
+		if err != nil {
+			return err
+		}
+		kubeClient, err := kube.NewClientset(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+		databaseConfigs, err := databases(kubeClient)
+		if err != nil {
+			return err
+		}
+		sourceKubeClient := kubeClient
+		if sourceContext != "" {
+			sourceKubeClient, err = kube.NewClientset(kubeconfig, sourceContext)
+			if err != nil {
+				return err
+			}
+		}
+		if sourceNamespace == "" {
+			sourceNamespace = namespace
+		}
+		builder := restore.NewRestoreDefinitionBuilder().
+			Namespace(namespace).
+			Operate(operateURL).
+			Tasklist(tasklistURL).
+			Optimize(optimizeURL).
+			Elastic(elasticURL, elasticSnapshotRepositoryName).
+			Zeebe(zeebeURL).
+			ZeebeStatefulSet(zeebeStatefulSet).
+			Components(components).
+			IndexPrefixes(indexPrefixes).
+			WipeAll(wipeAll).
+			Fresh(freshRestore).
+			Source(sourceKubeClient, sourceNamespace).
+			BackupStore(backupStore).
+			JobTimeout(jobTimeout).
+			JobOverrides(jobOverrides).
+			Databases(databaseConfigs).
+			Resources(resourceSelectors()).
+			IgnoreVersionCheck(ignoreVersionCheck).
+			IgnoreManifest(ignoreManifest).
+			HealthTimeout(healthTimeout).
+			AppSelector(appSelector).
+			ArgoCDNamespace(argoCDNamespace).
+			DryRun(dryRun).
+			PlanFile(planFile).
+			ApplyPlan(applyPlanFile).
+			Resume(resumeRestore).
+			Abort(abortRestore)
+		if restoreBefore != "" || restoreLatest {
+			backupID, err = selectBackup(builder.Build())
+			if err != nil {
+				return err
+			}
+		}
+		restoreDefinition := builder.BackupID(backupID).Build()
+
+		return restore.Restore(cmd.Context(), kubeClient, restoreDefinition)
+	}),
 }
 
 func init() {
 	rootCmd.AddCommand(restoreCmd)
 
-	restoreCmd.Flags().StringVar(&namespace, "namespace", "", "namespace where stuff runs")
 	restoreCmd.Flags().Int64Var(&backupID, "backup", 0, "ID of the the backup to restore")
 	restoreCmd.Flags().StringVar(&elasticURL, "elastic", "", "Pass in the url to the elastic mgmt endpoint")
 	restoreCmd.Flags().StringVar(&elasticSnapshotRepositoryName, "elastic-repository", "", "Name of the elasticsearch snapshot repository")
 	restoreCmd.Flags().StringVar(&operateURL, "operate", "", "Pass in the url to the operate mgmt endpoint")
 	restoreCmd.Flags().StringVar(&tasklistURL, "tasklist", "", "Pass in the url to the tasklist mgmt endpoint")
 	restoreCmd.Flags().StringVar(&optimizeURL, "optimize", "", "Pass in the url to the optimize mgmt endpoint")
+	restoreCmd.Flags().StringVar(&zeebeURL, "zeebe", "", "Pass in the url to the zeebe mgmt endpoint, used to check the backup against the broker topology")
+	restoreCmd.Flags().StringVar(&zeebeStatefulSet, "zeebe-statefulset", "", "Name of the zeebe broker statefulset. Default: detected")
+	restoreCmd.Flags().StringSliceVar(&restoreComponents, "components", nil, "Only restore these components, e.g. zeebe,operate,tasklist,optimize. Default: all")
+	restoreCmd.Flags().StringToStringVar(&indexPrefixes, "index-prefix", nil, "Override the index prefix of a component, e.g. operate=operate-,zeebe=zeebe-record")
+	restoreCmd.Flags().BoolVar(&wipeAll, "wipe-all", false, "Delete every index in the elasticsearch cluster, not only the Camunda ones")
+	restoreCmd.Flags().StringVar(&appSelector, "app-selector", restore.DefaultAppSelector, "Label selector of the deployments that are scaled down")
+	restoreCmd.Flags().StringVar(&argoCDNamespace, "argocd-namespace", restore.DefaultArgoCDNamespace, "Namespace of the argo cd applications managing the apps")
+	restoreCmd.Flags().BoolVar(&freshRestore, "fresh", false, "Restore into an empty or freshly installed namespace: create missing zeebe PVCs and don't scale apps that don't run")
+	restoreCmd.Flags().StringVar(&sourceContext, "source-context", "", "Kubeconfig context of the installation the backup was taken from, its zeebe statefulset is the template in --fresh mode. Default: --kube-context")
+	restoreCmd.Flags().StringVar(&sourceNamespace, "source-namespace", "", "Namespace of the installation the backup was taken from. Default: --namespace")
+
+	addBackupStoreFlags(restoreCmd)
+	addComponentFlags(restoreCmd)
+	restoreCmd.Flags().BoolVar(&ignoreVersionCheck, "ignore-version-check", false, "Restore even if the backup was taken on another camunda version")
+	restoreCmd.Flags().BoolVar(&ignoreManifest, "ignore-manifest", false, "Ask the components for their backups instead of trusting the signed manifest of the backup")
+	addJobFlags(restoreCmd)
+	restoreCmd.Flags().DurationVar(&healthTimeout, "health-timeout", restore.DefaultHealthTimeout, "How long to wait for each component to become healthy after the restore")
+
+	restoreCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what the restore would scale, delete and create, then stop")
+	restoreCmd.Flags().StringVar(&planFile, "plan-file", "", "Save the restore plan to this file and stop, to apply it later with --apply-plan")
+	restoreCmd.Flags().StringVar(&applyPlanFile, "apply-plan", "", "Run a restore plan previously saved with --plan-file")
+	restoreCmd.Flags().BoolVar(&resumeRestore, "resume", false, "Continue an interrupted restore from its last completed step")
+	restoreCmd.Flags().BoolVar(&abortRestore, "abort", false, "Scale the apps of an interrupted restore back to their original replicas and forget it")
+	restoreCmd.Flags().StringVar(&restoreBefore, "before", "", "Restore the newest backup completed in every component that was started before this time, e.g. 2026-10-17T14:00Z")
+	restoreCmd.Flags().BoolVar(&restoreLatest, "latest", false, "Restore the newest backup completed in every component")
+	restoreCmd.Flags().BoolVar(&assumeYes, "yes", false, "Don't ask to confirm the backup chosen by --before or --latest")
+	restoreCmd.MarkFlagsMutuallyExclusive("resume", "abort", "backup", "apply-plan", "before", "latest")
+	restoreCmd.MarkFlagsMutuallyExclusive("apply-plan", "plan-file")
+	restoreCmd.MarkFlagsMutuallyExclusive("apply-plan", "backup")
+
+}
+
+// selectBackup chooses the backup for --before or --latest and asks to confirm it, unless --yes is given or the
+// restore only shows or saves its plan.
+func selectBackup(definition restore.RestoreDefinition) (int64, error) {
+	var before time.Time
+	if restoreBefore != "" {
+		var err error
+		before, err = restore.ParseTime(restoreBefore)
+		if err != nil {
+			return 0, err
+		}
+	}
+	selection, err := restore.SelectBackup(context.Background(), definition, before)
+	if err != nil {
+		return 0, err
+	}
+	selection.Print(os.Stdout)
+	if selection.Chosen == nil {
+		return 0, errors.New("no backup completed in every component found")
+	}
+	chosen := selection.Chosen
+	fmt.Printf("Chosen backup %d taken at %s\n", chosen.ID, chosen.Time().UTC().Format(time.RFC3339))
+	if assumeYes || dryRun || planFile != "" {
+		return chosen.ID, nil
+	}
+
+	fmt.Print("Restore it? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return 0, errors.New("restore not confirmed, pass --yes to skip the confirmation")
+	}
+	return chosen.ID, nil
+}
+
+// addBackupStoreFlags adds the flags configuring where the Zeebe restore Jobs read the backup from.
+func addBackupStoreFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&backupStoreConfig.Type, "backup-store", "", "Zeebe backup store: s3, gcs, azure or filesystem. Default: detected from the ZEEBE_BROKER_DATA_BACKUP_* env of the zeebe statefulset")
+	cmd.Flags().StringVar(&backupStoreConfig.Bucket, "backup-store-bucket", "", "Bucket of the s3 or gcs backup store")
+	cmd.Flags().StringVar(&backupStoreConfig.BasePath, "backup-store-base-path", "", "Base path of the backup store, the container for azure")
+	cmd.Flags().StringVar(&backupStoreConfig.Region, "backup-store-region", "", "Region of the s3 backup store")
+	cmd.Flags().StringVar(&backupStoreConfig.Endpoint, "backup-store-endpoint", "", "Endpoint of the s3 or azure backup store")
+	cmd.Flags().StringVar(&backupStoreConfig.AccountName, "backup-store-account", "", "Account name of the azure backup store")
+	cmd.Flags().StringVar(&backupStoreConfig.Secret, "backup-store-secret", "", "Secret with the credentials: accessKey/secretKey for s3, the key file for gcs, accountKey for azure")
+	cmd.Flags().StringVar(&backupStoreConfig.SecretKey, "backup-store-secret-key", "", "Key in the secret holding the gcs key file or the azure account key")
+	cmd.Flags().StringVar(&backupStoreConfig.ServiceAccount, "backup-store-service-account", "", "Service account for IRSA or workload identity")
+	cmd.Flags().StringVar(&backupStoreConfig.ClaimName, "backup-store-claim", "", "PVC holding the backups of the filesystem backup store")
+}
+
+// addJobFlags adds the flags scheduling the Zeebe delete and restore Jobs, see parseJobOverrides.
+func addJobFlags(cmd *cobra.Command) {
+	cmd.Flags().DurationVar(&jobTimeout, "job-timeout", restore.DefaultJobTimeout, "How long the zeebe delete and restore jobs may run")
+	cmd.Flags().StringToStringVar(&jobNodeSelector, "job-node-selector", nil, "Node selector of the zeebe delete and restore jobs. Default: the one of the zeebe statefulset")
+	cmd.Flags().StringSliceVar(&jobTolerations, "job-toleration", nil, "Tolerations of the zeebe delete and restore jobs as key[=value]:effect. Default: the ones of the zeebe statefulset")
+	cmd.Flags().Int64Var(&jobRunAsUser, "job-run-as-user", 0, "User the zeebe delete and restore jobs run as. Default: the one of the zeebe statefulset")
+	cmd.Flags().Int64Var(&jobFSGroup, "job-fs-group", 0, "fsGroup of the zeebe delete and restore jobs. Default: the one of the zeebe statefulset")
+	cmd.Flags().StringToStringVar(&jobRequests, "job-requests", nil, "Resource requests of the zeebe delete and restore jobs, e.g. cpu=500m,memory=512Mi")
+	cmd.Flags().StringToStringVar(&jobLimits, "job-limits", nil, "Resource limits of the zeebe delete and restore jobs, e.g. cpu=1,memory=1Gi")
+	cmd.Flags().StringVar(&helperImage, "helper-image", restore.DefaultHelperImage, "Image of the job that wipes the zeebe data")
+	cmd.Flags().StringVar(&imageRegistry, "image-registry", "", "Registry mirror the helper image is pulled from, e.g. registry.local/mirror")
+	cmd.Flags().StringSliceVar(&imagePullSecrets, "image-pull-secret", nil, "Additional image pull secrets of the zeebe delete and restore jobs")
+	cmd.Flags().BoolVar(&wipeWithZeebeImage, "wipe-with-zeebe-image", false, "Wipe the zeebe data with the zeebe image instead of the helper image")
+}
 
+func parseJobOverrides(cmd *cobra.Command) (restore.JobOverrides, error) {
+	overrides := restore.JobOverrides{
+		NodeSelector:       jobNodeSelector,
+		HelperImage:        helperImage,
+		ImageRegistry:      imageRegistry,
+		ImagePullSecrets:   imagePullSecrets,
+		WipeWithZeebeImage: wipeWithZeebeImage,
+	}
+	var err error
+	overrides.Tolerations, err = restore.ParseTolerations(jobTolerations)
+	if err != nil {
+		return overrides, err
+	}
+	if cmd.Flags().Changed("job-run-as-user") {
+		overrides.RunAsUser = &jobRunAsUser
+	}
+	if cmd.Flags().Changed("job-fs-group") {
+		overrides.FSGroup = &jobFSGroup
+	}
+	overrides.Resources.Requests, err = restore.ParseResources(jobRequests)
+	if err != nil {
+		return overrides, err
+	}
+	overrides.Resources.Limits, err = restore.ParseResources(jobLimits)
+	return overrides, err
 }
@@ -18,9 +18,15 @@ package cmd
 import (
 	"os"
 
+	"c8backup/pkg/kube"
+	"c8backup/pkg/lock"
 	"github.com/spf13/cobra"
 )
 
+var kubeconfig string
+var kubeContext string
+var namespace string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "c8backup",
@@ -34,6 +40,11 @@ to quickly create a Cobra application.`,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if namespace == "" {
+			namespace = kube.Namespace(kubeconfig, kubeContext)
+		}
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -51,6 +62,11 @@ func init() {
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.c8backup.yaml)")
+	rootCmd.PersistentFlags().StringVar(&kubeconfig, "kubeconfig", "", "(optional) absolute path to the kubeconfig file")
+	rootCmd.PersistentFlags().StringVar(&kubeContext, "kube-context", "", "Name of the kubeconfig context to use. Default: the current context")
+	rootCmd.PersistentFlags().StringVar(&namespace, "namespace", "", "namespace where stuff runs. Defaults to the namespace of the current kube context")
+	rootCmd.PersistentFlags().BoolVar(&forceUnlock, "force-unlock", false, "Remove an existing backup lock before acquiring it. Only use this if the holder is known to be dead")
+	rootCmd.PersistentFlags().DurationVar(&lockTTL, "lock-ttl", lock.DefaultTTL, "Time after which the backup lock expires if it is not renewed")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"c8backup/pkg/kube"
+	"c8backup/pkg/restore"
+	"github.com/spf13/cobra"
+)
+
+var deepVerify bool
+
+// verifyCmd represents the verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that every part of a backup is present and consistent",
+	Long: `Check a backup without restoring it: the backups of all components are completed,
+their elasticsearch snapshots are in the repository without failed shards, every zeebe
+partition has a checkpoint and the zeebe records snapshot covers the checkpoints: it was
+taken after them for a hard pause of exporting, after the pause for a soft one.
+With --deep the repository is read back with the elasticsearch integrity check.
+Exits with 1 if a check failed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if backupID == 0 {
+			log.Fatalln("pass the backup to verify with --backup")
+		}
+		if elasticURL == "" {
+			log.Fatalln("pass the elasticsearch holding the snapshots with --elastic and --elastic-repository")
+		}
+		// Kubernetes is only needed for the manifest of the backup
+		kubeClient, err := kube.NewClientset(kubeconfig, kubeContext)
+		if err != nil {
+			log.Println("no kubernetes access, the manifest of the backup is not used:", err)
+		}
+		restoreDefinition := restore.NewRestoreDefinitionBuilder().
+			Namespace(namespace).
+			BackupID(backupID).
+			Operate(operateURL).
+			Tasklist(tasklistURL).
+			Optimize(optimizeURL).
+			Elastic(elasticURL, elasticSnapshotRepositoryName).
+			Zeebe(zeebeURL).
+			Source(kubeClient, namespace).
+			IgnoreManifest(ignoreManifest).
+			DeepVerify(deepVerify).
+			Build()
+
+		report := restore.VerifyBackup(restoreDefinition)
+		report.Print(os.Stdout)
+		if !report.Passed() {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+
+	verifyCmd.Flags().Int64Var(&backupID, "backup", 0, "ID of the the backup to verify")
+	verifyCmd.Flags().StringVar(&elasticURL, "elastic", "", "Pass in the url to the elastic mgmt endpoint")
+	verifyCmd.Flags().StringVar(&elasticSnapshotRepositoryName, "elastic-repository", "", "Name of the elasticsearch snapshot repository")
+	verifyCmd.Flags().StringVar(&operateURL, "operate", "", "Pass in the url to the operate mgmt endpoint")
+	verifyCmd.Flags().StringVar(&tasklistURL, "tasklist", "", "Pass in the url to the tasklist mgmt endpoint")
+	verifyCmd.Flags().StringVar(&optimizeURL, "optimize", "", "Pass in the url to the optimize mgmt endpoint")
+	verifyCmd.Flags().StringVar(&zeebeURL, "zeebe", "", "Pass in the url to the zeebe mgmt endpoint, tells the states of the partitions")
+	verifyCmd.Flags().BoolVar(&ignoreManifest, "ignore-manifest", false, "Ask the components for their backups instead of trusting the signed manifest of the backup")
+	verifyCmd.Flags().BoolVar(&deepVerify, "deep", false, "Read back every snapshot of the repository with _verify_integrity (elasticsearch 8.16+), can take long")
+	verifyCmd.MarkFlagsRequiredTogether("elastic", "elastic-repository")
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"c8backup/pkg/kube"
+	"c8backup/pkg/restore"
+	"github.com/spf13/cobra"
+)
+
+var renamePrefix string
+var verifyOperateURL string
+var keepVerification bool
+
+// verifyRestoreCmd represents the verify-restore command
+var verifyRestoreCmd = &cobra.Command{
+	Use:   "verify-restore",
+	Short: "Restore a backup into a scratch namespace to prove it is restorable",
+	Long: `Restore a backup into a scratch namespace without touching the backed up installation:
+the elasticsearch snapshots under renamed indices and the zeebe data into new PVCs of a clone
+of the broker statefulset. The brokers are booted and checked, then everything is deleted again.
+Exits with 1 if a check failed.`,
+	Run: withLock(func(cmd *cobra.Command, args []string) error {
+		if backupID == 0 {
+			return errors.New("pass the backup to verify with --backup")
+		}
+		backupStore, err := restore.NewBackupStore(backupStoreConfig)
+		if err != nil {
+			return err
+		}
+		jobOverrides, err := parseJobOverrides(cmd)
+		if err != nil {
+			return err
+		}
+		kubeClient, err := kube.NewClientset(kubeconfig, kubeContext)
+		if err != nil {
+			return err
+		}
+		sourceKubeClient := kubeClient
+		if sourceContext != "" {
+			sourceKubeClient, err = kube.NewClientset(kubeconfig, sourceContext)
+			if err != nil {
+				return err
+			}
+		}
+		if sourceNamespace == "" {
+			sourceNamespace = kube.Namespace(kubeconfig, sourceContext)
+		}
+		restoreDefinition := restore.NewRestoreDefinitionBuilder().
+			Namespace(namespace).
+			BackupID(backupID).
+			Operate(operateURL).
+			Tasklist(tasklistURL).
+			Optimize(optimizeURL).
+			Elastic(elasticURL, elasticSnapshotRepositoryName).
+			ZeebeStatefulSet(zeebeStatefulSet).
+			IndexPrefixes(indexPrefixes).
+			Source(sourceKubeClient, sourceNamespace).
+			BackupStore(backupStore).
+			JobTimeout(jobTimeout).
+			JobOverrides(jobOverrides).
+			RenamePrefix(renamePrefix).
+			VerifyOperate(verifyOperateURL).
+			Keep(keepVerification).
+			Build()
+
+		report := restore.VerifyRestore(cmd.Context(), kubeClient, restoreDefinition)
+		report.Print(os.Stdout)
+		if !report.Passed() {
+			return fmt.Errorf("verification of backup %d failed", backupID)
+		}
+		return nil
+	}),
+}
+
+func init() {
+	rootCmd.AddCommand(verifyRestoreCmd)
+
+	verifyRestoreCmd.Flags().Int64Var(&backupID, "backup", 0, "ID of the the backup to verify")
+	verifyRestoreCmd.Flags().StringVar(&elasticURL, "elastic", "", "Pass in the url to the elastic mgmt endpoint")
+	verifyRestoreCmd.Flags().StringVar(&elasticSnapshotRepositoryName, "elastic-repository", "", "Name of the elasticsearch snapshot repository")
+	verifyRestoreCmd.Flags().StringVar(&operateURL, "operate", "", "Pass in the url to the operate mgmt endpoint of the backed up installation")
+	verifyRestoreCmd.Flags().StringVar(&tasklistURL, "tasklist", "", "Pass in the url to the tasklist mgmt endpoint of the backed up installation")
+	verifyRestoreCmd.Flags().StringVar(&optimizeURL, "optimize", "", "Pass in the url to the optimize mgmt endpoint of the backed up installation")
+	verifyRestoreCmd.Flags().StringVar(&zeebeStatefulSet, "zeebe-statefulset", "", "Name of the zeebe broker statefulset of the backed up installation. Default: detected")
+	verifyRestoreCmd.Flags().StringToStringVar(&indexPrefixes, "index-prefix", nil, "Override the index prefix of a component, e.g. operate=operate-")
+	verifyRestoreCmd.Flags().StringVar(&sourceContext, "source-context", "", "Kubeconfig context of the backed up installation. Default: --kube-context")
+	verifyRestoreCmd.Flags().StringVar(&sourceNamespace, "source-namespace", "", "Namespace of the backed up installation. Default: the namespace of the source context")
+	verifyRestoreCmd.Flags().StringVar(&renamePrefix, "rename-prefix", "", "Prefix of the restored indices. Default: verify-<backup>-")
+	verifyRestoreCmd.Flags().StringVar(&verifyOperateURL, "verify-operate", "", "Mgmt endpoint of an operate installed in the scratch namespace, checked for health")
+	verifyRestoreCmd.Flags().BoolVar(&keepVerification, "keep", false, "Keep the restored indices, PVCs and brokers to look into a failed verification")
+	addBackupStoreFlags(verifyRestoreCmd)
+	addJobFlags(verifyRestoreCmd)
+	verifyRestoreCmd.MarkFlagsRequiredTogether("elastic", "elastic-repository")
+}
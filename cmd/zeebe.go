@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"c8backup/pkg/backup-client/zeebe"
+	"github.com/spf13/cobra"
+)
+
+var softPause bool
+
+// zeebeCmd groups the commands talking to the zeebe mgmt endpoint directly
+var zeebeCmd = &cobra.Command{
+	Use:   "zeebe",
+	Short: "Manage zeebe through its mgmt endpoint",
+}
+
+// exportingCmd is for manual recovery when a backup left exporting paused
+var exportingCmd = &cobra.Command{
+	Use:   "exporting",
+	Short: "Show, pause or resume zeebe exporting",
+	Long: `Show, pause or resume zeebe exporting. A backup pauses exporting while zeebe is backed
+up. If it is left paused, the brokers keep their log until it is exported and their disks
+fill up: resume it with 'c8backup zeebe exporting resume --zeebe <mgmt endpoint>'.`,
+}
+
+var exportingStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the exporter phase of the partitions of the broker behind the endpoint",
+	Run: func(cmd *cobra.Command, args []string) {
+		partitions, err := zeebeClient().Partitions(context.Background())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var ids []int
+		for id := range partitions {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		paused := false
+		for _, id := range ids {
+			partition := partitions[id]
+			phase := partition.ExporterPhase
+			if phase == "" {
+				phase = "unknown"
+			}
+			paused = paused || phase == zeebeBackup.ExporterPhasePaused || phase == zeebeBackup.ExporterPhaseSoftPaused
+			fmt.Printf("partition %d (%s): %s, exported %d of %d\n", id, partition.Role, phase, partition.ExportedPosition, partition.ProcessedPosition)
+		}
+		if paused {
+			fmt.Println("exporting is paused, resume it with: c8backup zeebe exporting resume")
+		}
+	},
+}
+
+var exportingPauseCmd = &cobra.Command{
+	Use:   "pause",
+	Short: "Pause exporting on all partitions",
+	Run: func(cmd *cobra.Command, args []string) {
+		mode, err := zeebeClient().PauseExporting(context.Background(), !softPause)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Printf("zeebe exporting paused (%s pause), the disks of the brokers fill up until it is resumed\n", mode)
+	},
+}
+
+var exportingResumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume exporting on all partitions",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := zeebeClient().ResumeExporting(context.Background())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("zeebe exporting resumed")
+	},
+}
+
+func zeebeClient() *zeebeBackup.BackupClient {
+	if zeebeURL == "" {
+		log.Fatalln("pass the zeebe mgmt endpoint with --zeebe")
+	}
+	return zeebeBackup.NewZeebeClient(zeebeURL)
+}
+
+func init() {
+	rootCmd.AddCommand(zeebeCmd)
+	zeebeCmd.AddCommand(exportingCmd)
+	exportingCmd.AddCommand(exportingStatusCmd, exportingPauseCmd, exportingResumeCmd)
+
+	zeebeCmd.PersistentFlags().StringVar(&zeebeURL, "zeebe", "", "Pass in the url to the zeebe mgmt endpoint")
+	exportingPauseCmd.Flags().BoolVar(&softPause, "soft", false, "Keep exporting but don't move the exporter positions, if the brokers support it")
+}
@@ -0,0 +1,64 @@
+package component
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrAlreadyExists is returned by Request if there is a backup with the ID already. Check for it with errors.Is.
+var ErrAlreadyExists = errors.New("backup already exists")
+
+// State is the normalized state of a backup of a component. The APIs of the components report their own states,
+// e.g. SUCCESS for Elasticsearch snapshots or INCOMPLETE for the webapps, each client maps them onto these.
+type State string
+
+const (
+	StateNotFound   State = "NOT_FOUND"
+	StateInProgress State = "IN_PROGRESS"
+	StateCompleted  State = "COMPLETED"
+	StateFailed     State = "FAILED"
+)
+
+// Done reports whether the backup won't change its state anymore.
+func (s State) Done() bool {
+	return s == StateCompleted || s == StateFailed
+}
+
+// Status is the state of one backup of a component.
+type Status struct {
+	ID    int64
+	State State
+	// Reason explains a failed backup, if the component tells
+	Reason string
+	// Snapshots are the Elasticsearch snapshots the backup consists of, if any
+	Snapshots []string
+}
+
+// Component is a part of the platform that takes backups of its own. The runner and the restore drive all of them
+// the same way, adding a component needs no change to the orchestration.
+type Component interface {
+	Name() string
+	// Request starts the backup with the given ID.
+	Request(ctx context.Context, id int64) error
+	// Status returns the state of the backup, StateNotFound if there is none with the ID.
+	Status(ctx context.Context, id int64) (*Status, error)
+	// List returns all backups of the component.
+	List(ctx context.Context) ([]Status, error)
+	Delete(ctx context.Context, id int64) error
+	// RestorePrerequisites returns the Elasticsearch snapshots to restore before the component is started again. It
+	// fails if the backup can't be restored.
+	RestorePrerequisites(ctx context.Context, id int64) ([]string, error)
+}
+
+// CompletedSnapshots is the RestorePrerequisites of a component whose backup is a set of snapshots.
+func CompletedSnapshots(ctx context.Context, c Component, id int64) ([]string, error) {
+	status, err := c.Status(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get backup %d of %s: %w", id, c.Name(), err)
+	}
+	if status.State != StateCompleted {
+		return nil, fmt.Errorf("backup %d of %s is %s, not %s", id, c.Name(), status.State, StateCompleted)
+	}
+	return status.Snapshots, nil
+}
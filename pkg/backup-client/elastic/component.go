@@ -0,0 +1,82 @@
+package elastic
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+// ZeebeRecords is the snapshot of the indices the Zeebe exporter writes to, taken while exporting is paused.
+type ZeebeRecords struct {
+	client      *Client
+	indexPrefix string
+}
+
+var _ component.Component = (*ZeebeRecords)(nil)
+
+// NewZeebeRecords snapshots the indices matching indexPrefix, e.g. zeebe-record*, with the client.
+func NewZeebeRecords(client *Client, indexPrefix string) *ZeebeRecords {
+	return &ZeebeRecords{client: client, indexPrefix: indexPrefix}
+}
+
+func (z ZeebeRecords) Name() string {
+	return "elasticsearch"
+}
+
+func (z ZeebeRecords) Request(ctx context.Context, id int64) error {
+	_, err := z.client.RequestSnapshot(ctx, id, z.indexPrefix)
+	return err
+}
+
+func (z ZeebeRecords) Status(ctx context.Context, id int64) (*component.Status, error) {
+	snapshots, err := z.client.GetBackup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if snapshots == nil || len(snapshots.Snapshots) == 0 {
+		return &component.Status{ID: id, State: component.StateNotFound}, nil
+	}
+	status := snapshotStatus(id, snapshots.Snapshots[0].Snapshot, snapshots.Snapshots[0].State)
+	return &status, nil
+}
+
+func (z ZeebeRecords) List(ctx context.Context) ([]component.Status, error) {
+	snapshots, err := z.client.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []component.Status
+	for _, snapshot := range snapshots.Snapshots {
+		id, err := strconv.ParseInt(strings.TrimPrefix(snapshot.Snapshot, ZeebeRecordsSnapshotPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, snapshotStatus(id, snapshot.Snapshot, snapshot.State))
+	}
+	return statuses, nil
+}
+
+func (z ZeebeRecords) Delete(ctx context.Context, id int64) error {
+	return z.client.DeleteSnapshot(ctx, id)
+}
+
+func (z ZeebeRecords) RestorePrerequisites(ctx context.Context, id int64) ([]string, error) {
+	return component.CompletedSnapshots(ctx, z, id)
+}
+
+// snapshotStatus maps the states of a snapshot: SUCCESS, IN_PROGRESS, PARTIAL, FAILED and INCOMPATIBLE.
+func snapshotStatus(id int64, name, state string) component.Status {
+	status := component.Status{ID: id, Snapshots: []string{name}}
+	switch state {
+	case "SUCCESS":
+		status.State = component.StateCompleted
+	case "IN_PROGRESS":
+		status.State = component.StateInProgress
+	default:
+		status.State = component.StateFailed
+		status.Reason = "snapshot is " + state
+	}
+	return status
+}
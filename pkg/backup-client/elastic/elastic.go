@@ -9,7 +9,12 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strings"
 	"time"
+
+	"c8backup/pkg/backup-client/component"
 )
 
 const snapshotEndpoint = "_snapshot"
@@ -71,6 +76,42 @@ func (e Client) GetBackup(ctx context.Context, id int64) (*SnapshotResponse, err
 	return nil, fmt.Errorf("error getting the elastic snapshot")
 }
 
+// ZeebeRecordsSnapshotPrefix is the name of the Zeebe records snapshot of a backup without the backup ID.
+const ZeebeRecordsSnapshotPrefix = "camunda_zeebe_records-"
+
+// ListBackups returns the Zeebe records snapshots of all backups.
+func (e Client) ListBackups(ctx context.Context) (*SnapshotResponse, error) {
+	return e.GetSnapshots(ctx, []string{ZeebeRecordsSnapshotPrefix + "*"})
+}
+
+// GetSnapshots returns the metadata, including the indices, of the named snapshots.
+func (e Client) GetSnapshots(ctx context.Context, snapshotNames []string) (*SnapshotResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.elasticRequestPath(strings.Join(snapshotNames, ",")), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("error getting the elastic snapshots %v: %s", snapshotNames, respBody)
+	}
+
+	var snapshotResponse SnapshotResponse
+	err = json.Unmarshal(respBody, &snapshotResponse)
+	if err != nil {
+		return nil, err
+	}
+	return &snapshotResponse, nil
+}
+
 func (e Client) RequestSnapshot(ctx context.Context, id int64, zeebeIndexPrefix string) (*SnapshotResponse, error) {
 	requestBody := []byte(fmt.Sprintf(`{"indices": "%s","feature_states": ["none"]}`, zeebeIndexPrefix))
 	snapshotName := fmt.Sprintf("%s-%d", "camunda_zeebe_records", id)
@@ -88,17 +129,17 @@ func (e Client) RequestSnapshot(ctx context.Context, id int64, zeebeIndexPrefix
 	respBody, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 
-	// Todo: Check response more
 	log.Printf("Elastic started snapshot of %s and response: response Body %s\n", requestBody, string(respBody))
 
-	switch resp.StatusCode {
-	case http.StatusOK:
+	if resp.StatusCode < 300 {
 		return &SnapshotResponse{}, nil
-	case http.StatusNotFound:
-		return nil, errors.New(string(respBody))
 	}
-
-	return nil, err
+	var errorBody ErrorResponse
+	if resp.StatusCode == http.StatusBadRequest && json.Unmarshal(respBody, &errorBody) == nil &&
+		errorBody.Error.Type == "invalid_snapshot_name_exception" {
+		return nil, fmt.Errorf("snapshot %s: %w", snapshotName, component.ErrAlreadyExists)
+	}
+	return nil, fmt.Errorf("error requesting snapshot %s (%d): %s", snapshotName, resp.StatusCode, respBody)
 }
 
 // elasticSnapshotZeebeRecords first tries to get information about the backup and returns the information. If there is no information
@@ -158,16 +199,89 @@ func (e Client) RestoreSnapshots(ctx context.Context, snapshotNames []string) er
 	return nil
 }
 
+// RestoreSnapshotRenamed restores all indices of the snapshot under the names rename_pattern/rename_replacement
+// produce. Aliases are left out, they would point the live aliases at the renamed indices.
+func (e Client) RestoreSnapshotRenamed(ctx context.Context, snapshotName, renamePattern, renameReplacement string) error {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"indices":              "*",
+		"include_aliases":      false,
+		"include_global_state": false,
+		"rename_pattern":       renamePattern,
+		"rename_replacement":   renameReplacement,
+	})
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx,
+		http.MethodPost,
+		e.elasticRequestPath(snapshotName)+"/_restore?wait_for_completion=true",
+		bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	request.Header.Add("Content-Type", "application/json; charset=utf-8")
+	resp, err := e.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error restoring snapshot %s renamed: %s", snapshotName, respBody)
+	}
+	return nil
+}
+
+// CountDocuments returns the number of documents in the index matching the query string query, all if it is empty.
+func (e Client) CountDocuments(ctx context.Context, index, query string) (int64, error) {
+	requestPath := "http://" + e.baseURL + "/" + index + "/_count"
+	if query != "" {
+		requestPath += "?q=" + url.QueryEscape(query)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := e.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return 0, fmt.Errorf("error counting documents of %s: %s", index, respBody)
+	}
+	var count CountResponse
+	err = json.Unmarshal(respBody, &count)
+	if err != nil {
+		return 0, err
+	}
+	return count.Count, nil
+}
+
 func (e Client) DeleteAllIndices(ctx context.Context) error {
+	indices, err := e.ListIndices(ctx)
+	if err != nil {
+		return err
+	}
+	return e.DeleteIndices(ctx, indices)
+}
+
+// ListIndices returns the names of all indices in the cluster.
+func (e Client) ListIndices(ctx context.Context) ([]string, error) {
 	request, err := http.NewRequestWithContext(ctx,
 		http.MethodGet,
 		"http://"+e.baseURL+"/*", nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	resp, err := e.httpClient.Do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var result map[string]interface{}
@@ -175,31 +289,54 @@ func (e Client) DeleteAllIndices(ctx context.Context) error {
 	respBody, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("resp status code greater than 300 Body: %s", respBody)
+	}
+
 	err = json.Unmarshal(respBody, &result)
 	if err != nil {
-		return fmt.Errorf("error unmarshalling json %v", err)
+		return nil, fmt.Errorf("error unmarshalling json %v", err)
 	}
 
-	if resp.StatusCode >= 300 {
-		return fmt.Errorf("resp status code greater than 300 Body: %s", respBody)
+	indices := make([]string, 0, len(result))
+	for index := range result {
+		indices = append(indices, index)
 	}
+	sort.Strings(indices)
+	return indices, nil
+}
 
-	for index, _ := range result {
-		fmt.Println(index)
-		err := e.deleteIndex(ctx, index)
+// DeleteIndices deletes the named indices in batches. Names containing wildcards or aliases like _all are
+// rejected, so a bad index list can never turn into deleting everything.
+func (e Client) DeleteIndices(ctx context.Context, indices []string) error {
+	for _, index := range indices {
+		if index == "" || index == "_all" || strings.ContainsAny(index, "*?,") {
+			return fmt.Errorf("refusing to delete index pattern %q, only concrete index names are allowed", index)
+		}
+	}
+
+	for start := 0; start < len(indices); start += deleteBatchSize {
+		end := start + deleteBatchSize
+		if end > len(indices) {
+			end = len(indices)
+		}
+		err := e.deleteIndices(ctx, indices[start:end])
 		if err != nil {
 			return err
 		}
-
 	}
 
 	return nil
 }
 
-func (e Client) deleteIndex(ctx context.Context, indexName string) error {
+const deleteBatchSize = 50
+
+func (e Client) deleteIndices(ctx context.Context, indices []string) error {
+	fmt.Println("deleting indices", indices)
+	// expand_wildcards=none makes elasticsearch itself refuse patterns, ignore_unavailable skips already deleted indices
 	request, err := http.NewRequestWithContext(ctx,
 		http.MethodDelete,
-		"http://"+e.baseURL+"/"+indexName, nil)
+		"http://"+e.baseURL+"/"+strings.Join(indices, ",")+"?expand_wildcards=none&ignore_unavailable=true", nil)
 	if err != nil {
 		return err
 	}
@@ -213,8 +350,55 @@ func (e Client) deleteIndex(ctx context.Context, indexName string) error {
 
 	log.Println(string(respBody))
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("error deleting els index %s", indexName)
+		return fmt.Errorf("error deleting els indices %v: %s", indices, respBody)
+	}
+
+	return nil
+}
+
+// ErrIntegrityUnsupported is returned by VerifyIntegrity on clusters without the _verify_integrity API, which came
+// with Elasticsearch 8.16.
+var ErrIntegrityUnsupported = errors.New("the repository integrity check is not supported by this elasticsearch")
+
+type integrityResponse struct {
+	Results struct {
+		Result         string `json:"result"`
+		TotalAnomalies int    `json:"total_anomalies"`
+	} `json:"results"`
+}
+
+// VerifyIntegrity reads back the blobs of every snapshot in the repository and fails if any is missing or corrupt.
+// It can take long on big repositories.
+func (e Client) VerifyIntegrity(ctx context.Context) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, e.elasticRequestPath("_verify_integrity"), nil)
+	if err != nil {
+		return err
 	}
+	// The check reads the whole repository, the 60s of the other requests are not enough
+	httpClient := *e.httpClient
+	httpClient.Timeout = 0
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	respBody, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
 
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed,
+		resp.StatusCode == http.StatusBadRequest && strings.Contains(string(respBody), "no handler found"):
+		return ErrIntegrityUnsupported
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("error verifying the integrity of repository %s: %s", e.backupRepositoryName, respBody)
+	}
+
+	var integrity integrityResponse
+	err = json.Unmarshal(respBody, &integrity)
+	if err != nil {
+		return fmt.Errorf("error unmarshalling json %v", err)
+	}
+	if integrity.Results.TotalAnomalies > 0 || (integrity.Results.Result != "" && integrity.Results.Result != "pass") {
+		return fmt.Errorf("repository %s has %d anomalies", e.backupRepositoryName, integrity.Results.TotalAnomalies)
+	}
 	return nil
 }
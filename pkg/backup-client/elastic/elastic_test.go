@@ -0,0 +1,119 @@
+package elastic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+func TestDeleteIndices(t *testing.T) {
+	var many []string
+	for i := 0; i < deleteBatchSize+1; i++ {
+		many = append(many, fmt.Sprintf("operate-list-view-%d", i))
+	}
+
+	tests := []struct {
+		name     string
+		indices  []string
+		requests int
+		wantErr  bool
+	}{
+		{name: "concrete names", indices: []string{"operate-list-view-8.3.0_", "tasklist-task-8.2.0_"}, requests: 1},
+		{name: "nothing to delete", indices: nil, requests: 0},
+		{name: "batched", indices: many, requests: 2},
+		{name: "wildcard", indices: []string{"operate-*"}, wantErr: true},
+		{name: "single character wildcard", indices: []string{"operate-list-view-8.3.?_"}, wantErr: true},
+		{name: "all", indices: []string{"_all"}, wantErr: true},
+		{name: "empty name", indices: []string{""}, wantErr: true},
+		{name: "comma separated", indices: []string{"operate-a,operate-b"}, wantErr: true},
+		{name: "pattern after concrete names", indices: []string{"operate-a", "*"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Method != http.MethodDelete || r.URL.Query().Get("expand_wildcards") != "none" {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				}
+				w.WriteHeader(http.StatusOK)
+				fmt.Fprint(w, `{"acknowledged":true}`)
+			}))
+			defer server.Close()
+
+			client := NewElasticClient(strings.TrimPrefix(server.URL, "http://"), "camunda")
+			err := client.DeleteIndices(context.Background(), test.indices)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("DeleteIndices() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr && requests != 0 {
+				t.Fatalf("DeleteIndices() sent %d requests for a refused pattern", requests)
+			}
+			if !test.wantErr && requests != test.requests {
+				t.Fatalf("DeleteIndices() sent %d requests, want %d", requests, test.requests)
+			}
+		})
+	}
+}
+
+func TestRequestSnapshot(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    error
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusOK, body: `{"accepted":true}`},
+		{
+			name:   "already exists",
+			status: http.StatusBadRequest,
+			body:   `{"error":{"type":"invalid_snapshot_name_exception","reason":"[backups:camunda_zeebe_records-1] Invalid snapshot name [camunda_zeebe_records-1], snapshot with the same name already exists"},"status":400}`,
+			want:   component.ErrAlreadyExists,
+		},
+		{
+			name:    "other bad request",
+			status:  http.StatusBadRequest,
+			body:    `{"error":{"type":"action_request_validation_exception","reason":"Validation Failed"},"status":400}`,
+			wantErr: true,
+		},
+		{
+			name:    "repository missing",
+			status:  http.StatusNotFound,
+			body:    `{"error":{"type":"repository_missing_exception","reason":"[backups] missing"},"status":404}`,
+			wantErr: true,
+		},
+		{name: "server error", status: http.StatusInternalServerError, body: `{"error":{"type":"exception"},"status":500}`, wantErr: true},
+		{name: "unavailable without body", status: http.StatusServiceUnavailable, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			}))
+			defer server.Close()
+
+			client := NewElasticClient(strings.TrimPrefix(server.URL, "http://"), "backups")
+			_, err := client.RequestSnapshot(context.Background(), 1, "zeebe-record*")
+			switch {
+			case test.want != nil:
+				if !errors.Is(err, test.want) {
+					t.Fatalf("RequestSnapshot() error = %v, want %v", err, test.want)
+				}
+			case (err != nil) != test.wantErr:
+				t.Fatalf("RequestSnapshot() error = %v, wantErr %v", err, test.wantErr)
+			case err != nil && errors.Is(err, component.ErrAlreadyExists):
+				t.Fatalf("RequestSnapshot() error = %v, want no %v", err, component.ErrAlreadyExists)
+			case err != nil && test.body != "" && !strings.Contains(err.Error(), test.body):
+				t.Fatalf("RequestSnapshot() error = %v, want it to hold the body", err)
+			}
+		})
+	}
+}
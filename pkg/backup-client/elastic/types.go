@@ -9,7 +9,7 @@ type SnapshotResponse struct {
 		Repository         string        `json:"repository"`
 		VersionId          int           `json:"version_id"`
 		Version            string        `json:"version"`
-		Indices            []interface{} `json:"indices"`
+		Indices            []string      `json:"indices"`
 		DataStreams        []interface{} `json:"data_streams"`
 		IncludeGlobalState bool          `json:"include_global_state"`
 		State              string        `json:"state"`
@@ -29,3 +29,16 @@ type SnapshotResponse struct {
 	Total     int `json:"total"`
 	Remaining int `json:"remaining"`
 }
+
+// ErrorResponse is the body of a failed request, e.g. of a snapshot whose name is taken already.
+type ErrorResponse struct {
+	Error struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	} `json:"error"`
+	Status int `json:"status"`
+}
+
+type CountResponse struct {
+	Count int64 `json:"count"`
+}
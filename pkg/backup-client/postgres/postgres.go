@@ -0,0 +1,310 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"c8backup/pkg/backup-client/component"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultImage runs pg_dump and pg_restore.
+const DefaultImage = "postgres:15.4"
+
+// DefaultStoreImage runs rclone, which copies the dumps to and from the backup store.
+const DefaultStoreImage = "rclone/rclone:1.64.2"
+
+const (
+	// dumpLabel names the database of a dump Job, backupLabel its backup ID
+	dumpLabel   = "c8backup/dump"
+	backupLabel = "c8backup/backup"
+	// RestoreJobSelector is carried by the pg_restore Jobs
+	RestoreJobSelector = "job=restore-database"
+	dumpMountPath      = "/dumps"
+)
+
+// storeScript sets $target to the dumps in the Zeebe backup store, configuring rclone from the
+// ZEEBE_BROKER_DATA_BACKUP_* env the store is applied with. Credentials not in the env are taken from the
+// environment of the pod, e.g. IRSA or workload identity.
+const storeScript = `set -e
+export RCLONE_CONFIG_STORE_ENV_AUTH=true
+case "$(echo "$ZEEBE_BROKER_DATA_BACKUP_STORE" | tr a-z A-Z)" in
+S3)
+  export RCLONE_CONFIG_STORE_TYPE=s3 RCLONE_CONFIG_STORE_PROVIDER=Other
+  export RCLONE_CONFIG_STORE_REGION="$ZEEBE_BROKER_DATA_BACKUP_S3_REGION" RCLONE_CONFIG_STORE_ENDPOINT="$ZEEBE_BROKER_DATA_BACKUP_S3_ENDPOINT"
+  if [ -n "$ZEEBE_BROKER_DATA_BACKUP_S3_ACCESSKEY" ]; then
+    export AWS_ACCESS_KEY_ID="$ZEEBE_BROKER_DATA_BACKUP_S3_ACCESSKEY" AWS_SECRET_ACCESS_KEY="$ZEEBE_BROKER_DATA_BACKUP_S3_SECRETKEY"
+  fi
+  target="store:$ZEEBE_BROKER_DATA_BACKUP_S3_BUCKETNAME/$ZEEBE_BROKER_DATA_BACKUP_S3_BASEPATH" ;;
+GCS)
+  export RCLONE_CONFIG_STORE_TYPE="google cloud storage" RCLONE_CONFIG_STORE_BUCKET_POLICY_ONLY=true
+  target="store:$ZEEBE_BROKER_DATA_BACKUP_GCS_BUCKETNAME/$ZEEBE_BROKER_DATA_BACKUP_GCS_BASEPATH" ;;
+AZURE)
+  export RCLONE_CONFIG_STORE_TYPE=azureblob RCLONE_CONFIG_STORE_ACCOUNT="$ZEEBE_BROKER_DATA_BACKUP_AZURE_ACCOUNTNAME"
+  export RCLONE_CONFIG_STORE_KEY="$ZEEBE_BROKER_DATA_BACKUP_AZURE_ACCOUNTKEY" RCLONE_CONFIG_STORE_ENDPOINT="$ZEEBE_BROKER_DATA_BACKUP_AZURE_ENDPOINT"
+  target="store:$ZEEBE_BROKER_DATA_BACKUP_AZURE_BASEPATH" ;;
+FILESYSTEM)
+  target="$ZEEBE_BROKER_DATA_BACKUP_FILESYSTEM_BASEPATH" ;;
+*)
+  echo "zeebe backup store '$ZEEBE_BROKER_DATA_BACKUP_STORE' not supported" >&2
+  exit 1 ;;
+esac
+target=$(echo "$target/c8backup-dumps" | sed 's#//*#/#g')
+`
+
+var jobBackoffLimit = int32(2)
+
+// Store configures the first container of a pod spec for the Zeebe backup store: the ZEEBE_BROKER_DATA_BACKUP_* env,
+// the volumes of its credentials and its ServiceAccount. The backup stores of the restore implement it.
+type Store interface {
+	Apply(spec *corev1.PodSpec)
+}
+
+// Config is a PostgreSQL database of a component and the store its dumps are kept in. Address is host[:port].
+type Config struct {
+	Name           string
+	Address        string
+	Database       string
+	User           string
+	PasswordSecret string
+	PasswordKey    string
+	Image          string
+	StoreImage     string
+	Store          Store
+}
+
+// Client backs up a database with pg_dump Jobs, which upload <name>/<backup ID>.dump to the c8backup-dumps folder
+// of the Zeebe backup store, so that the dumps are kept with the Zeebe backups and outside the namespace. The Jobs are
+// kept as the record of the backups: a backup is completed when its Job succeeded.
+type Client struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	config     Config
+}
+
+var _ component.Component = (*Client)(nil)
+
+func NewClient(kubeClient kubernetes.Interface, namespace string, config Config) *Client {
+	if config.Image == "" {
+		config.Image = DefaultImage
+	}
+	if config.StoreImage == "" {
+		config.StoreImage = DefaultStoreImage
+	}
+	if config.PasswordKey == "" {
+		config.PasswordKey = "password"
+	}
+	return &Client{kubeClient: kubeClient, namespace: namespace, config: config}
+}
+
+func (c Client) Name() string {
+	return c.config.Name
+}
+
+// Request dumps the database into the pod and uploads the dump once pg_dump is done.
+func (c Client) Request(ctx context.Context, id int64) error {
+	job := c.newJob(c.dumpJobName(id), id, fmt.Sprintf(`rclone copyto %s "$target/%s"`, c.localDump(id), c.dumpPath(id)))
+	writable(&job.Spec.Template.Spec)
+	spec := &job.Spec.Template.Spec
+	spec.InitContainers = append(spec.InitContainers, c.postgresContainer("pg-dump", "pg_dump --format=custom --file="+c.localDump(id)))
+	job.Labels[dumpLabel] = c.config.Name
+	_, err := c.kubeClient.BatchV1().Jobs(c.namespace).Create(ctx, job, metav1.CreateOptions{FieldManager: "c8-backup"})
+	if apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("%s backup %d: %w", c.config.Name, id, component.ErrAlreadyExists)
+	}
+	return err
+}
+
+func (c Client) Status(ctx context.Context, id int64) (*component.Status, error) {
+	job, err := c.kubeClient.BatchV1().Jobs(c.namespace).Get(ctx, c.dumpJobName(id), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return &component.Status{ID: id, State: component.StateNotFound}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := jobStatus(id, *job)
+	return &status, nil
+}
+
+func (c Client) List(ctx context.Context) ([]component.Status, error) {
+	jobs, err := c.kubeClient.BatchV1().Jobs(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: dumpLabel + "=" + c.config.Name})
+	if err != nil {
+		return nil, err
+	}
+	var statuses []component.Status
+	for _, job := range jobs.Items {
+		id, err := strconv.ParseInt(job.Labels[backupLabel], 10, 64)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, jobStatus(id, job))
+	}
+	return statuses, nil
+}
+
+// Delete removes the dump Job and the dump. The dump is removed by another Job, which deletes itself when done.
+func (c Client) Delete(ctx context.Context, id int64) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.kubeClient.BatchV1().Jobs(c.namespace).Delete(ctx, c.dumpJobName(id), metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	job := c.newJob(fmt.Sprintf("delete-%s-dump-%d", c.config.Name, id), id, fmt.Sprintf(`rclone deletefile "$target/%s" || echo "no dump to delete"`, c.dumpPath(id)))
+	writable(&job.Spec.Template.Spec)
+	ttl := int32(60)
+	job.Spec.TTLSecondsAfterFinished = &ttl
+	_, err = c.kubeClient.BatchV1().Jobs(c.namespace).Create(ctx, job, metav1.CreateOptions{FieldManager: "c8-backup"})
+	return err
+}
+
+// RestorePrerequisites needs no snapshots, the dump is restored by the Job of RestoreJob.
+func (c Client) RestorePrerequisites(ctx context.Context, id int64) ([]string, error) {
+	_, err := component.CompletedSnapshots(ctx, c, id)
+	return nil, err
+}
+
+// RestoreJob downloads the dump of the backup and restores it with pg_restore, replacing the objects in the database.
+// Nothing may be connected to the database meanwhile.
+func (c Client) RestoreJob(id int64) *batchv1.Job {
+	job := c.newJob(c.RestoreJobName(id), id, fmt.Sprintf(`rclone copyto "$target/%s" %s`, c.dumpPath(id), c.localDump(id)))
+	spec := &job.Spec.Template.Spec
+	// The store is applied to the first container, the download runs before pg_restore
+	spec.InitContainers = append(spec.InitContainers, spec.Containers[0])
+	spec.Containers = []corev1.Container{c.postgresContainer("pg-restore", fmt.Sprintf(
+		"pg_restore --clean --if-exists --no-owner --single-transaction --dbname=%s %s", c.config.Database, c.localDump(id)))}
+	key, value, _ := strings.Cut(RestoreJobSelector, "=")
+	job.Labels[key] = value
+	return job
+}
+
+func (c Client) RestoreJobName(id int64) string {
+	return fmt.Sprintf("restore-%s-%d", c.config.Name, id)
+}
+
+func (c Client) dumpJobName(id int64) string {
+	return fmt.Sprintf("%s-dump-%d", c.config.Name, id)
+}
+
+// dumpPath is the dump of the backup below the target of storeScript.
+func (c Client) dumpPath(id int64) string {
+	return fmt.Sprintf("%s/%d.dump", c.config.Name, id)
+}
+
+// localDump is the dump of the backup in the pod, shared by its containers.
+func (c Client) localDump(id int64) string {
+	return fmt.Sprintf("%s/%d.dump", dumpMountPath, id)
+}
+
+// newJob runs the rclone script with the target of storeScript set and the store applied. Its pod has the volume the
+// containers share the dump in.
+func (c Client) newJob(name string, id int64, script string) *batchv1.Job {
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: c.namespace,
+			Labels: map[string]string{
+				backupLabel: strconv.FormatInt(id, 10),
+			},
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &jobBackoffLimit,
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{
+						{
+							Name:    "store",
+							Image:   c.config.StoreImage,
+							Command: []string{"/bin/sh", "-c", storeScript + script},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "dumps",
+									MountPath: dumpMountPath,
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "dumps",
+							VolumeSource: corev1.VolumeSource{
+								EmptyDir: &corev1.EmptyDirVolumeSource{},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if c.config.Store != nil {
+		c.config.Store.Apply(&job.Spec.Template.Spec)
+	}
+	return job
+}
+
+// postgresContainer runs the script with the PG* env of the database and the dump volume mounted.
+func (c Client) postgresContainer(name, script string) corev1.Container {
+	host, port, found := strings.Cut(c.config.Address, ":")
+	if !found {
+		port = "5432"
+	}
+	return corev1.Container{
+		Name:    name,
+		Image:   c.config.Image,
+		Command: []string{"/bin/sh", "-c", script},
+		Env: []corev1.EnvVar{
+			{Name: "PGHOST", Value: host},
+			{Name: "PGPORT", Value: port},
+			{Name: "PGDATABASE", Value: c.config.Database},
+			{Name: "PGUSER", Value: c.config.User},
+			{
+				Name: "PGPASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: c.config.PasswordSecret},
+						Key:                  c.config.PasswordKey,
+					},
+				},
+			},
+		},
+		VolumeMounts: []corev1.VolumeMount{
+			{
+				Name:      "dumps",
+				MountPath: dumpMountPath,
+			},
+		},
+	}
+}
+
+// writable lifts the read-only mounts of the filesystem store, the Jobs uploading or deleting dumps write to it.
+func writable(spec *corev1.PodSpec) {
+	for i := range spec.Volumes {
+		if claim := spec.Volumes[i].PersistentVolumeClaim; claim != nil {
+			claim.ReadOnly = false
+		}
+	}
+	for i := range spec.Containers[0].VolumeMounts {
+		spec.Containers[0].VolumeMounts[i].ReadOnly = false
+	}
+}
+
+func jobStatus(id int64, job batchv1.Job) component.Status {
+	status := component.Status{ID: id, State: component.StateInProgress}
+	if job.Status.Succeeded > 0 {
+		status.State = component.StateCompleted
+	}
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			status.State = component.StateFailed
+			status.Reason = condition.Message
+		}
+	}
+	return status
+}
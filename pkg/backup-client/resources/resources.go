@@ -0,0 +1,310 @@
+package resources
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"c8backup/pkg/backup-client/component"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// resourcesLabel names the component of a backup Secret, backupLabel its backup ID
+	resourcesLabel = "c8backup/resources"
+	backupLabel    = "c8backup/backup"
+	// partLabel names the component of a Secret holding one kept object
+	partLabel = "c8backup/resources-part"
+	// keptAnnotation holds the keptObject of a part
+	keptAnnotation = "c8backup/kept"
+	partsKey       = "parts"
+	// resourcesKey holds all kept objects in backups taken before they were split into parts
+	resourcesKey = "resources.json"
+	// maxSecretSize is the most data the API server accepts in a Secret
+	maxSecretSize = 1024 * 1024
+)
+
+// Client keeps the Secrets and ConfigMaps matching a label selector, e.g. the secrets and config of Connectors.
+// A backup is a Secret per kept object in the namespace, since the Secrets must not end up anywhere less protected.
+// Its data is the data of the object, so it fits like the object did. An index Secret holding the number of parts is
+// written last, the backup is completed once it exists.
+type Client struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	selector   string
+}
+
+var _ component.Component = (*Client)(nil)
+
+// kept is what is restored of the Secrets and ConfigMaps.
+type kept struct {
+	Secrets    []corev1.Secret    `json:"secrets"`
+	ConfigMaps []corev1.ConfigMap `json:"configMaps"`
+}
+
+// keptObject is a kept Secret or ConfigMap without its data. BinaryKeys are the BinaryData of a ConfigMap.
+type keptObject struct {
+	Kind        string            `json:"kind"`
+	Name        string            `json:"name"`
+	Type        corev1.SecretType `json:"type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	BinaryKeys  []string          `json:"binaryKeys,omitempty"`
+}
+
+func NewClient(kubeClient kubernetes.Interface, namespace, name, selector string) *Client {
+	return &Client{kubeClient: kubeClient, namespace: namespace, name: name, selector: selector}
+}
+
+func (c Client) Name() string {
+	return c.name
+}
+
+func (c Client) Request(ctx context.Context, id int64) error {
+	secretClient := c.kubeClient.CoreV1().Secrets(c.namespace)
+	_, err := secretClient.Get(ctx, c.secretName(id), metav1.GetOptions{})
+	if err == nil {
+		return fmt.Errorf("%s backup %d: %w", c.name, id, component.ErrAlreadyExists)
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	listOptions := metav1.ListOptions{LabelSelector: c.selector}
+	secrets, err := secretClient.List(ctx, listOptions)
+	if err != nil {
+		return err
+	}
+	configMaps, err := c.kubeClient.CoreV1().ConfigMaps(c.namespace).List(ctx, listOptions)
+	if err != nil {
+		return err
+	}
+	var parts []*corev1.Secret
+	for _, secret := range secrets.Items {
+		object := keptObject{Kind: "Secret", Name: secret.Name, Type: secret.Type, Labels: secret.Labels, Annotations: secret.Annotations}
+		parts = append(parts, c.part(id, len(parts), object, secret.Data))
+	}
+	for _, configMap := range configMaps.Items {
+		object := keptObject{Kind: "ConfigMap", Name: configMap.Name, Labels: configMap.Labels, Annotations: configMap.Annotations}
+		data := map[string][]byte{}
+		for key, value := range configMap.Data {
+			data[key] = []byte(value)
+		}
+		for key, value := range configMap.BinaryData {
+			data[key] = value
+			object.BinaryKeys = append(object.BinaryKeys, key)
+		}
+		parts = append(parts, c.part(id, len(parts), object, data))
+	}
+
+	for _, part := range parts {
+		size := 0
+		for _, value := range part.Data {
+			size += len(value)
+		}
+		if size > maxSecretSize {
+			return fmt.Errorf("%s of %s holds %d bytes, more than the %d of a Secret", part.Annotations[keptAnnotation], c.name, size, maxSecretSize)
+		}
+	}
+	// Parts of an earlier attempt without an index are removed, it may have kept more objects
+	err = secretClient.DeleteCollection(ctx, metav1.DeleteOptions{}, c.partsOf(id))
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		_, err := secretClient.Create(ctx, part, metav1.CreateOptions{FieldManager: "c8-backup"})
+		if err != nil {
+			return err
+		}
+	}
+	_, err = secretClient.Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.secretName(id),
+			Namespace: c.namespace,
+			Labels: map[string]string{
+				resourcesLabel: c.name,
+				backupLabel:    strconv.FormatInt(id, 10),
+			},
+		},
+		Data: map[string][]byte{partsKey: []byte(strconv.Itoa(len(parts)))},
+	}, metav1.CreateOptions{FieldManager: "c8-backup"})
+	if apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("%s backup %d: %w", c.name, id, component.ErrAlreadyExists)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("kept %d secrets and %d configmaps of %s\n", len(secrets.Items), len(configMaps.Items), c.name)
+	return nil
+}
+
+// part is the Secret holding one kept object of the backup.
+func (c Client) part(id int64, index int, object keptObject, data map[string][]byte) *corev1.Secret {
+	meta, _ := json.Marshal(object)
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", c.secretName(id), index),
+			Namespace: c.namespace,
+			Labels: map[string]string{
+				partLabel:   c.name,
+				backupLabel: strconv.FormatInt(id, 10),
+			},
+			Annotations: map[string]string{keptAnnotation: string(meta)},
+		},
+		Data: data,
+	}
+}
+
+// Status is completed as soon as the backup Secret exists, it is written at once.
+func (c Client) Status(ctx context.Context, id int64) (*component.Status, error) {
+	_, err := c.kubeClient.CoreV1().Secrets(c.namespace).Get(ctx, c.secretName(id), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return &component.Status{ID: id, State: component.StateNotFound}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &component.Status{ID: id, State: component.StateCompleted}, nil
+}
+
+func (c Client) List(ctx context.Context) ([]component.Status, error) {
+	secrets, err := c.kubeClient.CoreV1().Secrets(c.namespace).List(ctx, metav1.ListOptions{LabelSelector: resourcesLabel + "=" + c.name})
+	if err != nil {
+		return nil, err
+	}
+	var statuses []component.Status
+	for _, secret := range secrets.Items {
+		id, err := strconv.ParseInt(secret.Labels[backupLabel], 10, 64)
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, component.Status{ID: id, State: component.StateCompleted})
+	}
+	return statuses, nil
+}
+
+// Delete removes the index first, so that a backup whose parts are partly deleted is not completed anymore.
+func (c Client) Delete(ctx context.Context, id int64) error {
+	err := c.kubeClient.CoreV1().Secrets(c.namespace).Delete(ctx, c.secretName(id), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	return c.kubeClient.CoreV1().Secrets(c.namespace).DeleteCollection(ctx, metav1.DeleteOptions{}, c.partsOf(id))
+}
+
+func (c Client) RestorePrerequisites(ctx context.Context, id int64) ([]string, error) {
+	_, err := component.CompletedSnapshots(ctx, c, id)
+	return nil, err
+}
+
+// Restore creates the kept Secrets and ConfigMaps of the backup in the namespace, replacing existing ones.
+func (c Client) Restore(ctx context.Context, id int64, kubeClient kubernetes.Interface, namespace string) error {
+	resources, err := c.read(ctx, id)
+	if err != nil {
+		return fmt.Errorf("unable to read %s backup %d: %w", c.name, id, err)
+	}
+
+	for _, secret := range resources.Secrets {
+		secret.Namespace = namespace
+		_, err := kubeClient.CoreV1().Secrets(namespace).Update(ctx, &secret, metav1.UpdateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsNotFound(err) {
+			_, err = kubeClient.CoreV1().Secrets(namespace).Create(ctx, &secret, metav1.CreateOptions{FieldManager: "c8-backup"})
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("restored secret", secret.Name)
+	}
+	for _, configMap := range resources.ConfigMaps {
+		configMap.Namespace = namespace
+		_, err := kubeClient.CoreV1().ConfigMaps(namespace).Update(ctx, &configMap, metav1.UpdateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsNotFound(err) {
+			_, err = kubeClient.CoreV1().ConfigMaps(namespace).Create(ctx, &configMap, metav1.CreateOptions{FieldManager: "c8-backup"})
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("restored configmap", configMap.Name)
+	}
+	return nil
+}
+
+// read returns the kept objects of the backup, from its parts or, for backups taken before the split, its index.
+func (c Client) read(ctx context.Context, id int64) (*kept, error) {
+	secrets := c.kubeClient.CoreV1().Secrets(c.namespace)
+	index, err := secrets.Get(ctx, c.secretName(id), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var resources kept
+	if data, found := index.Data[resourcesKey]; found {
+		err = json.Unmarshal(data, &resources)
+		return &resources, err
+	}
+	count, err := strconv.Atoi(string(index.Data[partsKey]))
+	if err != nil {
+		return nil, fmt.Errorf("index %s has no number of parts: %w", index.Name, err)
+	}
+	parts, err := secrets.List(ctx, c.partsOf(id))
+	if err != nil {
+		return nil, err
+	}
+	// Parts numbered beyond the count are left over from an interrupted attempt
+	var current []corev1.Secret
+	for _, part := range parts.Items {
+		index, err := strconv.Atoi(strings.TrimPrefix(part.Name, c.secretName(id)+"-"))
+		if err == nil && index < count {
+			current = append(current, part)
+		}
+	}
+	if len(current) != count {
+		return nil, fmt.Errorf("found %d of the %d parts", len(current), count)
+	}
+	for _, part := range current {
+		var object keptObject
+		err := json.Unmarshal([]byte(part.Annotations[keptAnnotation]), &object)
+		if err != nil {
+			return nil, fmt.Errorf("part %s: %w", part.Name, err)
+		}
+		meta := metav1.ObjectMeta{Name: object.Name, Labels: object.Labels, Annotations: object.Annotations}
+		if object.Kind == "Secret" {
+			resources.Secrets = append(resources.Secrets, corev1.Secret{ObjectMeta: meta, Type: object.Type, Data: part.Data})
+			continue
+		}
+		configMap := corev1.ConfigMap{ObjectMeta: meta, Data: map[string]string{}}
+		for key, value := range part.Data {
+			if contains(object.BinaryKeys, key) {
+				if configMap.BinaryData == nil {
+					configMap.BinaryData = map[string][]byte{}
+				}
+				configMap.BinaryData[key] = value
+			} else {
+				configMap.Data[key] = string(value)
+			}
+		}
+		resources.ConfigMaps = append(resources.ConfigMaps, configMap)
+	}
+	return &resources, nil
+}
+
+func (c Client) partsOf(id int64) metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s,%s=%d", partLabel, c.name, backupLabel, id)}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Client) secretName(id int64) string {
+	return fmt.Sprintf("c8backup-%s-%d", c.name, id)
+}
@@ -0,0 +1,149 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const (
+	namespace = "camunda"
+	selector  = "app.kubernetes.io/component=connectors"
+)
+
+// fakeClient is a fake clientset that deletes collections, which the fake tracker leaves to reactors.
+func fakeClient(objects ...runtime.Object) *fake.Clientset {
+	kubeClient := fake.NewSimpleClientset(objects...)
+	kubeClient.PrependReactor("delete-collection", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		selector := action.(k8stesting.DeleteCollectionAction).GetListRestrictions().Labels
+		list, err := kubeClient.Tracker().List(corev1.SchemeGroupVersion.WithResource("secrets"), corev1.SchemeGroupVersion.WithKind("Secret"), namespace)
+		if err != nil {
+			return true, nil, err
+		}
+		for _, secret := range list.(*corev1.SecretList).Items {
+			if selector.Matches(labels.Set(secret.Labels)) {
+				err := kubeClient.Tracker().Delete(corev1.SchemeGroupVersion.WithResource("secrets"), namespace, secret.Name)
+				if err != nil {
+					return true, nil, err
+				}
+			}
+		}
+		return true, nil, nil
+	})
+	return kubeClient
+}
+
+func keptSecret(name string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{"app.kubernetes.io/component": "connectors"}},
+		Data:       map[string][]byte{"token": []byte(name)},
+	}
+}
+
+// leftoverPart is a part of an earlier attempt of the backup that was interrupted before its index was written.
+func leftoverPart(client *Client, id int64, index int) *corev1.Secret {
+	return client.part(id, index, keptObject{Kind: "Secret", Name: fmt.Sprintf("removed-%d", index)}, map[string][]byte{"token": []byte("old")})
+}
+
+func TestRequestAfterInterruptedAttempt(t *testing.T) {
+	const id = 1679426843
+	tests := []struct {
+		name      string
+		kept      int
+		leftovers int
+	}{
+		{name: "no earlier attempt", kept: 2},
+		{name: "fewer objects than before", kept: 1, leftovers: 3},
+		{name: "same objects as before", kept: 2, leftovers: 2},
+		{name: "more objects than before", kept: 3, leftovers: 1},
+		{name: "nothing kept anymore", kept: 0, leftovers: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			var objects []runtime.Object
+			for i := 0; i < test.kept; i++ {
+				objects = append(objects, keptSecret(fmt.Sprintf("connectors-%d", i)))
+			}
+			client := NewClient(nil, namespace, "connectors", selector)
+			for i := 0; i < test.leftovers; i++ {
+				objects = append(objects, leftoverPart(client, id, i))
+			}
+			client.kubeClient = fakeClient(objects...)
+
+			err := client.Request(ctx, id)
+			if err != nil {
+				t.Fatalf("Request() error = %v", err)
+			}
+			parts, err := client.kubeClient.CoreV1().Secrets(namespace).List(ctx, client.partsOf(id))
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			if len(parts.Items) != test.kept {
+				t.Fatalf("Request() left %d parts, want %d", len(parts.Items), test.kept)
+			}
+			resources, err := client.read(ctx, id)
+			if err != nil {
+				t.Fatalf("read() error = %v", err)
+			}
+			if len(resources.Secrets) != test.kept {
+				t.Fatalf("read() = %d secrets, want %d", len(resources.Secrets), test.kept)
+			}
+			for _, secret := range resources.Secrets {
+				if string(secret.Data["token"]) != secret.Name {
+					t.Fatalf("read() secret %s holds %q of an earlier attempt", secret.Name, secret.Data["token"])
+				}
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	const id = 1679426843
+	tests := []struct {
+		name    string
+		count   string
+		parts   []int
+		want    int
+		wantErr bool
+	}{
+		{name: "all parts", count: "2", parts: []int{0, 1}, want: 2},
+		{name: "leftover parts of an earlier attempt", count: "2", parts: []int{0, 1, 2, 3}, want: 2},
+		{name: "part missing", count: "2", parts: []int{0, 3}, wantErr: true},
+		{name: "empty backup", count: "0", want: 0},
+		{name: "no number of parts", count: "", parts: []int{0}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := NewClient(nil, namespace, "connectors", selector)
+			objects := []runtime.Object{&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      client.secretName(id),
+					Namespace: namespace,
+					Labels:    map[string]string{resourcesLabel: "connectors", backupLabel: strconv.Itoa(id)},
+				},
+				Data: map[string][]byte{partsKey: []byte(test.count)},
+			}}
+			for _, index := range test.parts {
+				objects = append(objects, leftoverPart(client, id, index))
+			}
+			client.kubeClient = fakeClient(objects...)
+
+			resources, err := client.read(context.Background(), id)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("read() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && len(resources.Secrets) != test.want {
+				t.Fatalf("read() = %d secrets, want %d", len(resources.Secrets), test.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package webapps
 
 import (
-	"errors"
 	"fmt"
 	"net/http"
 	"time"
@@ -19,20 +18,26 @@ type BackupClient struct {
 	httpClient *http.Client
 }
 
+// supportedApps are the apps offering the backup API of the webapps under /actuator/backups.
+var supportedApps = map[string]bool{OptimizeApp: true, OperateApp: true, TasklistApp: true}
+
+// RegisterApp adds an app offering the backup API of the webapps.
+func RegisterApp(name string) {
+	supportedApps[name] = true
+}
+
 func NewBackupClient(name string, baseURL string) (*BackupClient, error) {
-	switch name {
-	case OptimizeApp, OperateApp, TasklistApp:
-		url := fmt.Sprintf("http://%s/actuator/backups", baseURL)
-		return &BackupClient{
-			name:    name,
-			baseURL: url,
-			httpClient: &http.Client{
-				Timeout: time.Second * 10,
-			},
-		}, nil
-	default:
-		return nil, errors.New("application not supported")
+	if !supportedApps[name] {
+		return nil, fmt.Errorf("application %s not supported", name)
 	}
+	url := fmt.Sprintf("http://%s/actuator/backups", baseURL)
+	return &BackupClient{
+		name:    name,
+		baseURL: url,
+		httpClient: &http.Client{
+			Timeout: time.Second * 10,
+		},
+	}, nil
 }
 
 func (b BackupClient) Name() string {
@@ -0,0 +1,63 @@
+package webapps
+
+import (
+	"context"
+	"errors"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+var _ component.Component = (*BackupClient)(nil)
+
+func (b BackupClient) Request(ctx context.Context, id int64) error {
+	return b.RequestBackup(ctx, id)
+}
+
+func (b BackupClient) Status(ctx context.Context, id int64) (*component.Status, error) {
+	backup, err := b.GetBackup(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return &component.Status{ID: id, State: component.StateNotFound}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := backup.status()
+	return &status, nil
+}
+
+func (b BackupClient) List(ctx context.Context) ([]component.Status, error) {
+	backups, err := b.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []component.Status
+	for _, backup := range backups {
+		statuses = append(statuses, backup.status())
+	}
+	return statuses, nil
+}
+
+func (b BackupClient) Delete(ctx context.Context, id int64) error {
+	return b.DeleteBackup(ctx, id)
+}
+
+func (b BackupClient) RestorePrerequisites(ctx context.Context, id int64) ([]string, error) {
+	return component.CompletedSnapshots(ctx, b, id)
+}
+
+// status maps the states of the webapps: COMPLETED, IN_PROGRESS, FAILED, INCOMPATIBLE and INCOMPLETE.
+func (r BackupResponse) status() component.Status {
+	status := component.Status{ID: int64(r.BackupId), Reason: r.FailureReason}
+	switch r.State {
+	case "COMPLETED":
+		status.State = component.StateCompleted
+	case "IN_PROGRESS":
+		status.State = component.StateInProgress
+	default:
+		status.State = component.StateFailed
+	}
+	for _, detail := range r.Details {
+		status.Snapshots = append(status.Snapshots, detail.SnapshotName)
+	}
+	return status
+}
@@ -0,0 +1,69 @@
+package webapps
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+// The errors of the backup API. Check for them with errors.Is, the returned errors are BackupErrors.
+var (
+	ErrNotFound           = errors.New("backup not found")
+	ErrRepositoryMissing  = errors.New("snapshot repository missing")
+	ErrAlreadyExists      = component.ErrAlreadyExists
+	ErrElasticUnavailable = errors.New("elasticsearch unavailable")
+)
+
+// BackupError is an error response of the backup API of an app. Err is one of the errors above, or nil if the
+// response matches none of them.
+type BackupError struct {
+	App     string
+	Status  int
+	Message string
+	Err     error
+}
+
+func (e *BackupError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v (%d): %s", e.App, e.Err, e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s: request failed (%d): %s", e.App, e.Status, e.Message)
+}
+
+func (e *BackupError) Unwrap() error {
+	return e.Err
+}
+
+// parseError decodes both error formats, see ErrorBackupResponse, and classifies the error by status and message.
+func parseError(app string, status int, body []byte) error {
+	var errorBody ErrorBackupResponse
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &errorBody) == nil {
+		for _, m := range []string{errorBody.DetailedMessage, errorBody.ErrorMessage, errorBody.Message, errorBody.Error} {
+			if m != "" && m != "No message available" {
+				message = m
+				break
+			}
+		}
+	}
+
+	backupError := &BackupError{App: app, Status: status, Message: message}
+	lower := strings.ToLower(message)
+	switch {
+	case strings.Contains(lower, "repository") && (strings.Contains(lower, "missing") || strings.Contains(lower, "no repository") ||
+		strings.Contains(lower, "does not exist") || strings.Contains(lower, "not found")):
+		backupError.Err = ErrRepositoryMissing
+	case status == http.StatusConflict || strings.Contains(lower, "already exists"):
+		backupError.Err = ErrAlreadyExists
+	case status == http.StatusBadGateway || status == http.StatusServiceUnavailable ||
+		strings.Contains(lower, "connect to elasticsearch") || strings.Contains(lower, "elasticsearch is not available"):
+		backupError.Err = ErrElasticUnavailable
+	case status == http.StatusNotFound:
+		backupError.Err = ErrNotFound
+	}
+	return backupError
+}
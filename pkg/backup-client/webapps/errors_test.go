@@ -0,0 +1,129 @@
+package webapps
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBackupIDUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    BackupID
+		wantErr bool
+	}{
+		{name: "number", data: `1679426843`, want: 1679426843},
+		{name: "string", data: `"1679426843"`, want: 1679426843},
+		{name: "null", data: `null`, want: 0},
+		{name: "empty string", data: `""`, want: 0},
+		{name: "not a number", data: `"latest"`, wantErr: true},
+		{name: "fraction", data: `1.5`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var response BackupResponse
+			err := json.Unmarshal([]byte(`{"backupId":`+test.data+`,"state":"COMPLETED"}`), &response)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Unmarshal(%s) error = %v, wantErr %v", test.data, err, test.wantErr)
+			}
+			if !test.wantErr && response.BackupId != test.want {
+				t.Fatalf("Unmarshal(%s) = %d, want %d", test.data, response.BackupId, test.want)
+			}
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    error
+		message string
+	}{
+		{
+			name:    "optimize not found",
+			status:  http.StatusNotFound,
+			body:    `{"errorCode":"notFoundError","errorMessage":"The server could not find the requested resource.","detailedMessage":"No Optimize backup with ID [1679426843] could be found."}`,
+			want:    ErrNotFound,
+			message: "No Optimize backup with ID [1679426843] could be found.",
+		},
+		{
+			name:    "operate not found without message",
+			status:  http.StatusNotFound,
+			body:    `{"status":404,"error":"Not Found","message":"No message available","path":"/actuator/backups/1679431820"}`,
+			want:    ErrNotFound,
+			message: "Not Found",
+		},
+		{
+			name:    "repository missing",
+			status:  http.StatusBadRequest,
+			body:    `{"status":400,"error":"Bad Request","message":"No repository with name [camunda] could be found."}`,
+			want:    ErrRepositoryMissing,
+			message: "No repository with name [camunda] could be found.",
+		},
+		{
+			name:   "repository not found wins over the status",
+			status: http.StatusNotFound,
+			body:   `{"errorCode":"notFoundError","detailedMessage":"Snapshot repository camunda not found"}`,
+			want:   ErrRepositoryMissing,
+		},
+		{
+			name:   "conflict",
+			status: http.StatusConflict,
+			body:   `{"status":409,"error":"Conflict","message":"A backup with ID [1] already exists."}`,
+			want:   ErrAlreadyExists,
+		},
+		{
+			name:   "already exists without conflict status",
+			status: http.StatusBadRequest,
+			body:   `{"errorCode":"badRequestError","detailedMessage":"A backup with ID [1] already exists."}`,
+			want:   ErrAlreadyExists,
+		},
+		{
+			name:   "elasticsearch unavailable",
+			status: http.StatusInternalServerError,
+			body:   `{"status":500,"error":"Internal Server Error","message":"Could not connect to Elasticsearch"}`,
+			want:   ErrElasticUnavailable,
+		},
+		{
+			name:   "service unavailable",
+			status: http.StatusServiceUnavailable,
+			body:   `upstream connect error`,
+			want:   ErrElasticUnavailable,
+		},
+		{
+			name:    "plain text body",
+			status:  http.StatusInternalServerError,
+			body:    "  something broke\n",
+			message: "something broke",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := parseError("operate", test.status, []byte(test.body))
+			var backupError *BackupError
+			if !errors.As(err, &backupError) {
+				t.Fatalf("parseError() = %v, want a *BackupError", err)
+			}
+			if backupError.Status != test.status || backupError.App != "operate" {
+				t.Fatalf("parseError() = %+v, want app operate and status %d", backupError, test.status)
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Fatalf("parseError() = %v, want %v", err, test.want)
+			}
+			if test.want == nil && backupError.Err != nil {
+				t.Fatalf("parseError() = %v, want no classification", err)
+			}
+			if test.message != "" && backupError.Message != test.message {
+				t.Fatalf("parseError() message = %q, want %q", backupError.Message, test.message)
+			}
+			if !strings.HasPrefix(err.Error(), "operate: ") {
+				t.Fatalf("parseError() = %q, want it to name the app", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package webapps
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// infoResponse is the part of /actuator/info holding the version of the app.
+type infoResponse struct {
+	Build struct {
+		Version string `json:"version"`
+	} `json:"build"`
+	Version string `json:"version"`
+}
+
+// Version probes /actuator/info for the version of the app.
+func (b BackupClient) Version(ctx context.Context) (string, error) {
+	requestPath := strings.TrimSuffix(b.baseURL, "/backups") + "/info"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", parseError(b.name, resp.StatusCode, respBody)
+	}
+
+	var info infoResponse
+	err = json.Unmarshal(respBody, &info)
+	if err != nil {
+		return "", err
+	}
+	version := info.Build.Version
+	if version == "" {
+		version = info.Version
+	}
+	if version == "" {
+		return "", fmt.Errorf("%s reports no version in /actuator/info", b.name)
+	}
+	return version, nil
+}
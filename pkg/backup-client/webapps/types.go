@@ -1,6 +1,11 @@
 package webapps
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // Optimize:
 //
@@ -34,13 +39,27 @@ type ErrorBackupResponse struct {
 	Path      string    `json:"path"`
 }
 
+// BackupID is the ID of a backup. Operate and Optimize return it as a number, some versions of Tasklist as a string.
+type BackupID int64
+
+func (id *BackupID) UnmarshalJSON(data []byte) error {
+	unquoted := strings.Trim(string(data), `"`)
+	if unquoted == "null" || unquoted == "" {
+		*id = 0
+		return nil
+	}
+	value, err := strconv.ParseInt(unquoted, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid backup id %s: %w", data, err)
+	}
+	*id = BackupID(value)
+	return nil
+}
+
 type BackupResponse struct {
-	// Operate has int64 backupID
-	// Optimize has int64 backupID
-	// Tasklist has string backupID // To be fixed - soon TM
-	BackupId      int64  `json:"backupId"`
-	State         string `json:"state"`
-	FailureReason string `json:"failureReason"`
+	BackupId      BackupID `json:"backupId"`
+	State         string   `json:"state"`
+	FailureReason string   `json:"failureReason"`
 	Details       []struct {
 		SnapshotName string   `json:"snapshotName"`
 		State        string   `json:"state"`
@@ -52,11 +71,3 @@ type BackupResponse struct {
 type backupRequestBody struct {
 	BackupID string `json:"backupId"`
 }
-
-type backupRequestErrorMessage struct {
-	Timestamp time.Time `json:"timestamp"`
-	Status    int       `json:"status"`
-	Error     string    `json:"error"`
-	Message   string    `json:"message"`
-	Path      string    `json:"path"`
-}
@@ -28,6 +28,9 @@ func (b BackupClient) GetBackup(ctx context.Context, id int64) (*BackupResponse,
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode >= 300 {
+		return nil, parseError(b.name, resp.StatusCode, respBody)
+	}
 
 	var successBackupResp BackupResponse
 	err = json.Unmarshal(respBody, &successBackupResp)
@@ -38,6 +41,36 @@ func (b BackupClient) GetBackup(ctx context.Context, id int64) (*BackupResponse,
 	return &successBackupResp, err
 }
 
+// ListBackups returns all backups of the app.
+func (b BackupClient) ListBackups(ctx context.Context) ([]BackupResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.baseURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, parseError(b.name, resp.StatusCode, respBody)
+	}
+
+	var backups []BackupResponse
+	err = json.Unmarshal(respBody, &backups)
+	if err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
 func (b BackupClient) RequestBackup(ctx context.Context, id int64) error {
 	body := backupRequestBody{BackupID: strconv.FormatInt(id, 10)}
 	backupRequestJson, _ := json.Marshal(body)
@@ -61,13 +94,7 @@ func (b BackupClient) RequestBackup(ctx context.Context, id int64) error {
 	}
 
 	if resp.StatusCode >= 300 {
-		var backupErrorBody backupRequestErrorMessage
-		err = json.Unmarshal(respBody, &backupErrorBody)
-		if err != nil {
-			return fmt.Errorf("backup Request failed for backupId %d, %w", id, err)
-		}
-
-		return fmt.Errorf("backup Request failed: %s, %s, %w", backupErrorBody.Error, backupErrorBody.Message, err)
+		return parseError(b.name, resp.StatusCode, respBody)
 	}
 	return nil
 }
@@ -86,20 +113,12 @@ func (b BackupClient) DeleteBackup(ctx context.Context, id int64) error {
 	}
 	defer resp.Body.Close()
 
-	_, err = io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
-
-	switch resp.StatusCode {
-	case http.StatusNoContent:
-		return nil
-	case http.StatusBadRequest:
-		return err
-	default:
-		//400 Bad Request	There is an issue with the request, for example the repository name specified in the Optimize configuration does not exist. Refer to returned error message for details.
-		//500 Server Error	An error occurred, for example the snapshot repository does not exist. Refer to the returned error message for details.
-		//502 Bad Gateway	Optimize has encountered issues while trying to connect to Elasticsearch.
-		return err
+	if resp.StatusCode >= 300 {
+		return parseError(b.name, resp.StatusCode, respBody)
 	}
+	return nil
 }
@@ -0,0 +1,73 @@
+package zeebeBackup
+
+import (
+	"context"
+	"fmt"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+var _ component.Component = (*BackupClient)(nil)
+
+func (z BackupClient) Name() string {
+	return "zeebe"
+}
+
+func (z BackupClient) Request(ctx context.Context, id int64) error {
+	return z.RequestBackup(ctx, id)
+}
+
+func (z BackupClient) Status(ctx context.Context, id int64) (*component.Status, error) {
+	backup, err := z.GetBackup(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if backup == nil {
+		return &component.Status{ID: id, State: component.StateNotFound}, nil
+	}
+	status := backup.status()
+	return &status, nil
+}
+
+func (z BackupClient) List(ctx context.Context) ([]component.Status, error) {
+	backups, err := z.ListBackups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var statuses []component.Status
+	for _, backup := range backups {
+		statuses = append(statuses, backup.status())
+	}
+	return statuses, nil
+}
+
+func (z BackupClient) Delete(ctx context.Context, id int64) error {
+	return z.DeleteBackup(ctx, id)
+}
+
+// RestorePrerequisites needs no snapshots, the brokers restore their data from the backup store into their PVCs.
+func (z BackupClient) RestorePrerequisites(ctx context.Context, id int64) ([]string, error) {
+	_, err := component.CompletedSnapshots(ctx, z, id)
+	return nil, err
+}
+
+// status maps the states of Zeebe: COMPLETED, IN_PROGRESS, FAILED, INCOMPLETE and DOES_NOT_EXIST.
+func (r BackupResponse) status() component.Status {
+	status := component.Status{ID: r.BackupId}
+	switch r.State {
+	case "COMPLETED":
+		status.State = component.StateCompleted
+	case "IN_PROGRESS":
+		status.State = component.StateInProgress
+	case "DOES_NOT_EXIST":
+		status.State = component.StateNotFound
+	default:
+		status.State = component.StateFailed
+	}
+	for _, detail := range r.Details {
+		if detail.State != "COMPLETED" && status.State == component.StateFailed {
+			status.Reason = fmt.Sprintf("partition %d is %s", detail.PartitionId, detail.State)
+		}
+	}
+	return status
+}
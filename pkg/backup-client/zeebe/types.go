@@ -23,3 +23,28 @@ type BackupResponse struct {
 		BrokerVersion      string    `json:"brokerVersion"`
 	} `json:"details"`
 }
+
+// Exporter phases of a partition
+const (
+	ExporterPhaseExporting  = "EXPORTING"
+	ExporterPhasePaused     = "PAUSED"
+	ExporterPhaseSoftPaused = "SOFT_PAUSED"
+)
+
+// PauseMode is how exporting was paused. With a soft pause the exporters keep exporting but don't move their
+// positions, so the brokers still keep their log. Older brokers ignore the request for a soft pause and pause hard.
+type PauseMode string
+
+const (
+	PauseHard PauseMode = "hard"
+	PauseSoft PauseMode = "soft"
+	// PauseUnknown is a requested soft pause the brokers didn't report the phase of
+	PauseUnknown PauseMode = "unknown"
+)
+
+type PartitionStatus struct {
+	Role              string `json:"role"`
+	ExporterPhase     string `json:"exporterPhase"`
+	ProcessedPosition int64  `json:"processedPosition"`
+	ExportedPosition  int64  `json:"exportedPosition"`
+}
@@ -36,6 +36,108 @@ func (z BackupClient) StopExporting(ctx context.Context) error {
 	return z.exportingRequest(ctx, "pause")
 }
 
+// PauseExporting pauses exporting, preferring a soft pause unless hard is set. Whether the brokers paused softly is
+// detected from the exporter phase of the partitions of the broker behind the endpoint.
+func (z BackupClient) PauseExporting(ctx context.Context, hard bool) (PauseMode, error) {
+	if hard {
+		return PauseHard, z.StopExporting(ctx)
+	}
+	err := z.exportingRequest(ctx, "pause?soft=true")
+	if err != nil {
+		return "", err
+	}
+	partitions, err := z.Partitions(ctx)
+	if err != nil {
+		return PauseUnknown, nil
+	}
+	mode := PauseUnknown
+	for _, partition := range partitions {
+		switch partition.ExporterPhase {
+		case ExporterPhaseSoftPaused:
+			mode = PauseSoft
+		case ExporterPhasePaused:
+			return PauseHard, nil
+		}
+	}
+	return mode, nil
+}
+
+// Partitions returns the partitions of the broker behind the mgmt endpoint by ID, including their exporter phase.
+// Brokers before 8.2 don't report the phase.
+func (z BackupClient) Partitions(ctx context.Context) (map[int]PartitionStatus, error) {
+	requestPath := fmt.Sprintf("%sactuator/partitions", z.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestPath, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := z.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("getting the zeebe partitions failed: %s", respBody)
+	}
+
+	var partitions map[int]PartitionStatus
+	err = json.Unmarshal(respBody, &partitions)
+	if err != nil {
+		return nil, err
+	}
+	return partitions, nil
+}
+
+// infoResponse is the part of /actuator/info holding the version of the broker.
+type infoResponse struct {
+	Build struct {
+		Version string `json:"version"`
+	} `json:"build"`
+	Version string `json:"version"`
+}
+
+// Version probes /actuator/info for the version of the broker behind the mgmt endpoint.
+func (z BackupClient) Version(ctx context.Context) (string, error) {
+	requestPath := fmt.Sprintf("%sactuator/info", z.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := z.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("getting the zeebe info failed: %s", respBody)
+	}
+
+	var info infoResponse
+	err = json.Unmarshal(respBody, &info)
+	if err != nil {
+		return "", err
+	}
+	version := info.Build.Version
+	if version == "" {
+		version = info.Version
+	}
+	if version == "" {
+		return "", fmt.Errorf("zeebe reports no version in /actuator/info")
+	}
+	return version, nil
+}
+
 func (z BackupClient) exportingRequest(ctx context.Context, action action) error {
 	path := "actuator/exporting"
 	requestPath := fmt.Sprintf("%s%s/%s", z.baseURL, path, action)
@@ -102,6 +204,37 @@ func (z BackupClient) GetBackup(ctx context.Context, id int64) (*BackupResponse,
 	return &backupResp, err
 }
 
+// ListBackups returns all backups of the cluster.
+func (z BackupClient) ListBackups(ctx context.Context) ([]BackupResponse, error) {
+	requestPath := fmt.Sprintf("%sactuator/backups", z.baseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Content-Type", "application/json; charset=utf-8")
+
+	resp, err := z.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("listing the zeebe backups failed: %s", respBody)
+	}
+
+	var backups []BackupResponse
+	err = json.Unmarshal(respBody, &backups)
+	if err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
 func (z BackupClient) RequestBackup(ctx context.Context, id int64) error {
 	requestPath := fmt.Sprintf("%sactuator/backups", z.baseURL)
 	body := BackupRequestBody{BackupID: id}
@@ -147,7 +280,7 @@ func (z BackupClient) RequestBackup(ctx context.Context, id int64) error {
 }
 
 func (z BackupClient) DeleteBackup(ctx context.Context, id int64) error {
-	requestPath := fmt.Sprintf("%s/%d", z.baseURL, id)
+	requestPath := fmt.Sprintf("%sactuator/backups/%d", z.baseURL, id)
 	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, requestPath, nil)
 	if err != nil {
 		return err
@@ -0,0 +1,150 @@
+package compat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNewer is wrapped by Platform for versions newer than the matrix, or that are no version like latest. They are
+// warned about instead of failing the check.
+var ErrNewer = errors.New("newer than the compatibility matrix")
+
+// platformVersions maps the minor versions of Camunda 8 to the minor versions of its components whose backups can be
+// restored together. Optimize had versions of its own until 8.3. Backups of one platform version can only be
+// restored into the same platform version.
+var platformVersions = map[string]map[string]string{
+	"8.1": {"zeebe": "8.1", "operate": "8.1", "tasklist": "8.1", "optimize": "3.9"},
+	"8.2": {"zeebe": "8.2", "operate": "8.2", "tasklist": "8.2", "optimize": "3.10"},
+	"8.3": {"zeebe": "8.3", "operate": "8.3", "tasklist": "8.3", "optimize": "8.3"},
+	"8.4": {"zeebe": "8.4", "operate": "8.4", "tasklist": "8.4", "optimize": "8.4"},
+	"8.5": {"zeebe": "8.5", "operate": "8.5", "tasklist": "8.5", "optimize": "8.5"},
+	"8.6": {"zeebe": "8.6", "operate": "8.6", "tasklist": "8.6", "optimize": "8.6"},
+	"8.7": {"zeebe": "8.7", "operate": "8.7", "tasklist": "8.7", "optimize": "8.7"},
+}
+
+// Minor returns major.minor of a version, e.g. 8.2 of 8.2.5 or 8.3.0-alpha1.
+func Minor(version string) string {
+	version = strings.TrimPrefix(version, "v")
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return version
+	}
+	return parts[0] + "." + strings.SplitN(parts[1], "-", 2)[0]
+}
+
+// Platform returns the minor version of Camunda 8 the version of the component belongs to. A version newer than the
+// matrix is taken to be its platform version, since the components share it from 8.3 on, and wraps ErrNewer. So does
+// a version that can't be parsed, with an empty platform.
+func Platform(component, version string) (string, error) {
+	minor := Minor(version)
+	for platform, components := range platformVersions {
+		if components[component] == minor {
+			return platform, nil
+		}
+	}
+	newer, parsed := newerThanMatrix(minor)
+	if !parsed {
+		return "", fmt.Errorf("%s %s is not a version, it is taken to be %w", component, version, ErrNewer)
+	}
+	if newer {
+		return minor, fmt.Errorf("%s %s is %w, it is taken to be camunda %s", component, version, ErrNewer, minor)
+	}
+	return "", fmt.Errorf("%s %s is not in the compatibility matrix", component, version)
+}
+
+// newerThanMatrix reports whether the minor version is newer than the newest platform version, and whether it could
+// be parsed at all.
+func newerThanMatrix(minor string) (bool, bool) {
+	major, minorNumber, ok := parseMinor(minor)
+	if !ok {
+		return false, false
+	}
+	for platform := range platformVersions {
+		platformMajor, platformMinor, _ := parseMinor(platform)
+		if major < platformMajor || (major == platformMajor && minorNumber <= platformMinor) {
+			return false, true
+		}
+	}
+	return true, true
+}
+
+func parseMinor(minor string) (int, int, bool) {
+	majorPart, minorPart, found := strings.Cut(minor, ".")
+	major, err := strconv.Atoi(majorPart)
+	if !found || err != nil {
+		return 0, 0, false
+	}
+	minorNumber, err := strconv.Atoi(minorPart)
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minorNumber, true
+}
+
+// CheckConsistent checks that the versions of the components belong to one platform version and returns it.
+// Components with an unknown version, empty in the map, are skipped. Versions newer than the matrix are warnings.
+func CheckConsistent(versions map[string]string) (string, []string, error) {
+	platforms := map[string][]string{}
+	var warnings []string
+	for _, component := range sortedKeys(versions) {
+		if versions[component] == "" {
+			continue
+		}
+		platform, err := Platform(component, versions[component])
+		if errors.Is(err, ErrNewer) {
+			warnings = append(warnings, err.Error())
+		} else if err != nil {
+			return "", warnings, err
+		}
+		if platform != "" {
+			platforms[platform] = append(platforms[platform], fmt.Sprintf("%s %s", component, versions[component]))
+		}
+	}
+	if len(platforms) > 1 {
+		var mixed []string
+		for _, platform := range sortedKeys(platforms) {
+			mixed = append(mixed, fmt.Sprintf("%s: %s", platform, strings.Join(platforms[platform], ", ")))
+		}
+		return "", warnings, fmt.Errorf("components of different camunda versions are mixed (%s)", strings.Join(mixed, "; "))
+	}
+	for platform := range platforms {
+		return platform, warnings, nil
+	}
+	return "", warnings, nil
+}
+
+// CheckRestore checks that the backup was taken on the platform version that is running. It returns the warnings
+// about versions newer than the matrix.
+func CheckRestore(backup, running map[string]string) ([]string, error) {
+	backupPlatform, backupWarnings, err := CheckConsistent(backup)
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	runningPlatform, runningWarnings, err := CheckConsistent(running)
+	if err != nil {
+		return nil, fmt.Errorf("running installation: %w", err)
+	}
+	var warnings []string
+	for _, warning := range backupWarnings {
+		warnings = append(warnings, "backup: "+warning)
+	}
+	for _, warning := range runningWarnings {
+		warnings = append(warnings, "running installation: "+warning)
+	}
+	if backupPlatform != "" && runningPlatform != "" && backupPlatform != runningPlatform {
+		return warnings, fmt.Errorf("the backup was taken on camunda %s, restoring it into camunda %s is not supported", backupPlatform, runningPlatform)
+	}
+	return warnings, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,120 @@
+package compat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+		version   string
+		want      string
+		wantErr   error
+		fails     bool
+	}{
+		{name: "zeebe", component: "zeebe", version: "8.2.5", want: "8.2"},
+		{name: "prerelease", component: "operate", version: "8.3.0-alpha1", want: "8.3"},
+		{name: "v prefix", component: "tasklist", version: "v8.4.1", want: "8.4"},
+		{name: "optimize before 8.3", component: "optimize", version: "3.10.2", want: "8.2"},
+		{name: "optimize from 8.3", component: "optimize", version: "8.3.0", want: "8.3"},
+		{name: "newer minor", component: "zeebe", version: "8.9.0", want: "8.9", wantErr: ErrNewer},
+		{name: "newer major", component: "zeebe", version: "9.0.0", want: "9.0", wantErr: ErrNewer},
+		{name: "not a version", component: "zeebe", version: "latest", want: "", wantErr: ErrNewer},
+		{name: "older than the matrix", component: "zeebe", version: "8.0.3", fails: true},
+		{name: "unknown optimize", component: "optimize", version: "3.8.0", fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Platform(test.component, test.version)
+			switch {
+			case test.fails:
+				if err == nil || errors.Is(err, ErrNewer) {
+					t.Fatalf("Platform(%s, %s) error = %v, want a failure", test.component, test.version, err)
+				}
+				return
+			case test.wantErr != nil:
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Platform(%s, %s) error = %v, want %v", test.component, test.version, err, test.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("Platform(%s, %s) error = %v", test.component, test.version, err)
+			}
+			if got != test.want {
+				t.Fatalf("Platform(%s, %s) = %q, want %q", test.component, test.version, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckConsistent(t *testing.T) {
+	tests := []struct {
+		name     string
+		versions map[string]string
+		want     string
+		warnings int
+		wantErr  bool
+	}{
+		{name: "one platform", versions: map[string]string{"zeebe": "8.2.5", "operate": "8.2.3", "optimize": "3.10.1"}, want: "8.2"},
+		{name: "unknown versions skipped", versions: map[string]string{"zeebe": "8.3.1", "operate": ""}, want: "8.3"},
+		{name: "nothing known", versions: map[string]string{"zeebe": ""}, want: ""},
+		{name: "mixed", versions: map[string]string{"zeebe": "8.2.5", "operate": "8.3.0"}, wantErr: true},
+		{name: "newer than the matrix", versions: map[string]string{"zeebe": "8.9.0", "operate": "8.9.1"}, want: "8.9", warnings: 2},
+		{name: "newer mixed with known", versions: map[string]string{"zeebe": "8.9.0", "operate": "8.7.0"}, warnings: 1, wantErr: true},
+		{name: "not a version", versions: map[string]string{"zeebe": "latest", "operate": "8.7.0"}, want: "8.7", warnings: 1},
+		{name: "not in the matrix", versions: map[string]string{"zeebe": "8.0.0"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, warnings, err := CheckConsistent(test.versions)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CheckConsistent() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if len(warnings) != test.warnings {
+				t.Fatalf("CheckConsistent() warnings = %q, want %d", warnings, test.warnings)
+			}
+			if !test.wantErr && got != test.want {
+				t.Fatalf("CheckConsistent() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckRestore(t *testing.T) {
+	tests := []struct {
+		name     string
+		backup   map[string]string
+		running  map[string]string
+		warnings []string
+		wantErr  bool
+	}{
+		{name: "same platform", backup: map[string]string{"zeebe": "8.2.1"}, running: map[string]string{"zeebe": "8.2.7", "operate": "8.2.7"}},
+		{name: "unknown backup versions", backup: map[string]string{"zeebe": ""}, running: map[string]string{"zeebe": "8.3.0"}},
+		{name: "different platform", backup: map[string]string{"zeebe": "8.2.1"}, running: map[string]string{"zeebe": "8.3.0"}, wantErr: true},
+		{name: "inconsistent backup", backup: map[string]string{"zeebe": "8.2.1", "operate": "8.3.0"}, running: map[string]string{"zeebe": "8.3.0"}, wantErr: true},
+		{name: "inconsistent running installation", backup: map[string]string{"zeebe": "8.3.0"}, running: map[string]string{"zeebe": "8.3.0", "tasklist": "8.4.0"}, wantErr: true},
+		{
+			name:     "newer on both sides",
+			backup:   map[string]string{"zeebe": "8.9.0"},
+			running:  map[string]string{"zeebe": "8.9.2"},
+			warnings: []string{"backup: zeebe 8.9.0 is newer than the compatibility matrix, it is taken to be camunda 8.9", "running installation: zeebe 8.9.2 is newer than the compatibility matrix, it is taken to be camunda 8.9"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warnings, err := CheckRestore(test.backup, test.running)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("CheckRestore() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if len(warnings) != len(test.warnings) {
+				t.Fatalf("CheckRestore() warnings = %q, want %q", warnings, test.warnings)
+			}
+			for i := range warnings {
+				if warnings[i] != test.warnings[i] {
+					t.Fatalf("CheckRestore() warning = %q, want %q", warnings[i], test.warnings[i])
+				}
+			}
+		})
+	}
+}
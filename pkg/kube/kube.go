@@ -0,0 +1,31 @@
+package kube
+
+import (
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// clientConfig loads the kubeconfig the same way kubectl does. An empty path falls back to $KUBECONFIG,
+// ~/.kube/config and finally the in-cluster service account. An empty context is the current context.
+func clientConfig(kubeconfig, kubeContext string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+}
+
+func NewClientset(kubeconfig, kubeContext string) (*kubernetes.Clientset, error) {
+	config, err := clientConfig(kubeconfig, kubeContext).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
+
+// Namespace returns the namespace of the kubeconfig context, or "default" if there is none.
+func Namespace(kubeconfig, kubeContext string) string {
+	namespace, _, err := clientConfig(kubeconfig, kubeContext).Namespace()
+	if err != nil || namespace == "" {
+		return "default"
+	}
+	return namespace
+}
@@ -0,0 +1,141 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileLock is the fallback used when there is no Kubernetes access. It only protects runs on the same host.
+type FileLock struct {
+	path     string
+	identity string
+	ttl      time.Duration
+
+	stopRenew context.CancelFunc
+	renewDone <-chan struct{}
+	lost      chan struct{}
+}
+
+type fileLockContent struct {
+	HolderIdentity string    `json:"holderIdentity"`
+	RenewTime      time.Time `json:"renewTime"`
+	TTLSeconds     int64     `json:"ttlSeconds"`
+}
+
+func NewFileLock(namespace, identity string, ttl time.Duration) *FileLock {
+	return &FileLock{
+		path:     filepath.Join(os.TempDir(), fmt.Sprintf("%s-%s", DefaultName, namespace)),
+		identity: identity,
+		ttl:      ttl,
+	}
+}
+
+func (f *FileLock) Lock(ctx context.Context) error {
+	err := f.create()
+	if errors.Is(err, fs.ErrExist) {
+		content, readErr := f.read()
+		if readErr == nil && content.HolderIdentity != f.identity &&
+			time.Now().Before(content.RenewTime.Add(time.Duration(content.TTLSeconds)*time.Second)) {
+			return fmt.Errorf("%w: %s is held by %s", ErrLocked, f.path, content.HolderIdentity)
+		}
+		fmt.Printf("lock file %s is stale, taking over\n", f.path)
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		err = f.create()
+		if errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("%w: %s was taken concurrently", ErrLocked, f.path)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("acquired lock file %s as %s\n", f.path, f.identity)
+	renewCtx, cancel := context.WithCancel(context.Background())
+	f.stopRenew = cancel
+	f.lost = make(chan struct{})
+	f.renewDone = renewLoop(renewCtx, f.ttl, f.renew, f.lost)
+	return nil
+}
+
+func (f *FileLock) Lost() <-chan struct{} {
+	return f.lost
+}
+
+// renew rewrites the lock file, unless another run took it over after it expired.
+func (f *FileLock) renew(context.Context) error {
+	content, err := f.read()
+	if err == nil && content.HolderIdentity != f.identity {
+		return fmt.Errorf("%w: %s was taken over by %s", ErrLocked, f.path, content.HolderIdentity)
+	}
+	return f.write()
+}
+
+func (f *FileLock) create() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(f.content())
+}
+
+func (f *FileLock) write() error {
+	data, err := json.Marshal(f.content())
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(f.path, data, 0o600)
+}
+
+func (f *FileLock) read() (*fileLockContent, error) {
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return nil, err
+	}
+	var content fileLockContent
+	err = json.Unmarshal(data, &content)
+	if err != nil {
+		return nil, err
+	}
+	return &content, nil
+}
+
+func (f *FileLock) content() fileLockContent {
+	return fileLockContent{
+		HolderIdentity: f.identity,
+		RenewTime:      time.Now(),
+		TTLSeconds:     int64(f.ttl.Seconds()),
+	}
+}
+
+func (f *FileLock) Unlock(ctx context.Context) error {
+	if f.stopRenew != nil {
+		f.stopRenew()
+		<-f.renewDone
+	}
+	content, err := f.read()
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err == nil && content.HolderIdentity != f.identity {
+		return nil
+	}
+	return os.Remove(f.path)
+}
+
+// ForceUnlock removes the lock file regardless of its holder.
+func (f *FileLock) ForceUnlock(ctx context.Context) error {
+	err := os.Remove(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
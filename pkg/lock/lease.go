@@ -0,0 +1,160 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// LeaseLock is a cluster wide lock backed by a coordination.k8s.io Lease in the target namespace.
+type LeaseLock struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	name       string
+	identity   string
+	ttl        time.Duration
+
+	stopRenew context.CancelFunc
+	renewDone <-chan struct{}
+	lost      chan struct{}
+}
+
+func NewLeaseLock(kubeClient kubernetes.Interface, namespace, identity string, ttl time.Duration) *LeaseLock {
+	return &LeaseLock{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		name:       DefaultName,
+		identity:   identity,
+		ttl:        ttl,
+	}
+}
+
+func (l *LeaseLock) Lock(ctx context.Context) error {
+	leases := l.kubeClient.CoordinationV1().Leases(l.namespace)
+	now := metav1.NewMicroTime(time.Now())
+	ttlSeconds := int32(l.ttl.Seconds())
+
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      l.name,
+				Namespace: l.namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "c8backup",
+				},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &l.identity,
+				LeaseDurationSeconds: &ttlSeconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsAlreadyExists(err) {
+			return fmt.Errorf("%w: lease %s/%s was created concurrently", ErrLocked, l.namespace, l.name)
+		}
+		if err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if holder, held := l.heldByOther(lease); held {
+			return fmt.Errorf("%w: lease %s/%s is held by %s", ErrLocked, l.namespace, l.name, holder)
+		}
+		lease.Spec.HolderIdentity = &l.identity
+		lease.Spec.LeaseDurationSeconds = &ttlSeconds
+		lease.Spec.AcquireTime = &now
+		lease.Spec.RenewTime = &now
+		// The update carries the resourceVersion we read, so a concurrent takeover fails with a conflict.
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsConflict(err) {
+			return fmt.Errorf("%w: lease %s/%s was taken concurrently", ErrLocked, l.namespace, l.name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("acquired lease %s/%s as %s\n", l.namespace, l.name, l.identity)
+	renewCtx, cancel := context.WithCancel(context.Background())
+	l.stopRenew = cancel
+	l.lost = make(chan struct{})
+	l.renewDone = renewLoop(renewCtx, l.ttl, l.renew, l.lost)
+	return nil
+}
+
+func (l *LeaseLock) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *LeaseLock) heldByOther(lease *coordinationv1.Lease) (string, bool) {
+	spec := lease.Spec
+	if spec.HolderIdentity == nil || *spec.HolderIdentity == "" || *spec.HolderIdentity == l.identity {
+		return "", false
+	}
+	if spec.RenewTime == nil || spec.LeaseDurationSeconds == nil {
+		return *spec.HolderIdentity, true
+	}
+	expiry := spec.RenewTime.Add(time.Duration(*spec.LeaseDurationSeconds) * time.Second)
+	if time.Now().After(expiry) {
+		fmt.Printf("lease %s/%s held by %s expired at %s, taking over\n", l.namespace, l.name, *spec.HolderIdentity, expiry)
+		return "", false
+	}
+	return *spec.HolderIdentity, true
+}
+
+func (l *LeaseLock) renew(ctx context.Context) error {
+	leases := l.kubeClient.CoordinationV1().Leases(l.namespace)
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		return fmt.Errorf("%w: lease %s/%s was taken over", ErrLocked, l.namespace, l.name)
+	}
+	now := metav1.NewMicroTime(time.Now())
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{FieldManager: "c8-backup"})
+	if apierrors.IsConflict(err) {
+		return fmt.Errorf("%w: lease %s/%s was taken concurrently", ErrLocked, l.namespace, l.name)
+	}
+	return err
+}
+
+func (l *LeaseLock) Unlock(ctx context.Context) error {
+	if l.stopRenew != nil {
+		l.stopRenew()
+		<-l.renewDone
+	}
+	leases := l.kubeClient.CoordinationV1().Leases(l.namespace)
+	lease, err := leases.Get(ctx, l.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if lease.Spec.HolderIdentity == nil || *lease.Spec.HolderIdentity != l.identity {
+		return nil
+	}
+	return leases.Delete(ctx, l.name, metav1.DeleteOptions{
+		Preconditions: &metav1.Preconditions{ResourceVersion: &lease.ResourceVersion},
+	})
+}
+
+// ForceUnlock removes the lease regardless of its holder.
+func (l *LeaseLock) ForceUnlock(ctx context.Context) error {
+	err := l.kubeClient.CoordinationV1().Leases(l.namespace).Delete(ctx, l.name, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	DefaultName = "c8backup-lock"
+	DefaultTTL  = time.Minute
+	// MinTTL is the shortest TTL, Leases count it in whole seconds
+	MinTTL = time.Second
+)
+
+var ErrLocked = errors.New("lock is held by another c8backup run")
+
+// Locker guards backup, restore, delete and prune against concurrent runs. A held lock is renewed in the
+// background until Unlock is called, so a crashed holder releases it after the TTL.
+type Locker interface {
+	Lock(ctx context.Context) error
+	Unlock(ctx context.Context) error
+	ForceUnlock(ctx context.Context) error
+	// Lost is closed once the held lock was taken over or couldn't be renewed within the TTL, another run may hold
+	// it then.
+	Lost() <-chan struct{}
+}
+
+// Identity names the holder of a lock. Inside a pod the hostname is the pod name.
+func Identity() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// renewLoop calls renew every ttl/3 until ctx is cancelled. It closes lost and stops renewing once renew reports
+// ErrLocked or hasn't succeeded for ttl.
+func renewLoop(ctx context.Context, ttl time.Duration, renew func(context.Context) error, lost chan<- struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		renewed := time.Now()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := renew(ctx)
+				if err == nil {
+					renewed = time.Now()
+					continue
+				}
+				if ctx.Err() != nil {
+					return
+				}
+				fmt.Println("unable to renew lock", err)
+				if errors.Is(err, ErrLocked) || time.Since(renewed) >= ttl {
+					fmt.Println("lock lost")
+					close(lost)
+					return
+				}
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,225 @@
+package manifest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	manifestKey = "manifest.json"
+	backupLabel = "c8backup/backup"
+	// keySecret holds the key the manifests of a namespace are signed with
+	keySecret = "c8backup-manifest-key"
+	keyKey    = "key"
+)
+
+// ToolVersion is set at build time with -ldflags "-X c8backup/pkg/manifest.ToolVersion=<version>". Without it the
+// version of the module is used.
+var ToolVersion = ""
+
+var (
+	ErrUnsigned         = errors.New("manifest is not signed")
+	ErrInvalidSignature = errors.New("manifest signature does not match")
+)
+
+// Manifest is the record of a backup, kept in a ConfigMap in the namespace of the backed up installation. It is
+// signed, so that a restore can trust it instead of asking every component again.
+type Manifest struct {
+	BackupID    int64     `json:"backupId"`
+	CreatedAt   time.Time `json:"createdAt"`
+	ToolVersion string    `json:"toolVersion"`
+	Duration    string    `json:"duration"`
+	// Versions are the versions of the components, empty if unknown
+	Versions   map[string]string `json:"versions"`
+	Components []Component       `json:"components"`
+	Zeebe      *Zeebe            `json:"zeebe,omitempty"`
+	Signature  string            `json:"signature,omitempty"`
+}
+
+// Component is the outcome of the backup of one component. Indices are those of its Elasticsearch snapshots.
+type Component struct {
+	Name      string          `json:"name"`
+	State     component.State `json:"state"`
+	Reason    string          `json:"reason,omitempty"`
+	Snapshots []string        `json:"snapshots,omitempty"`
+	Indices   []string        `json:"indices,omitempty"`
+	Duration  string          `json:"duration"`
+}
+
+// Zeebe are the partitions of the Zeebe backup and how exporting was paused for it. ClusterSize is 0 if unknown.
+type Zeebe struct {
+	Partitions  []Partition `json:"partitions"`
+	ClusterSize int         `json:"clusterSize,omitempty"`
+	// PauseMode is hard, soft or unknown, see zeebeBackup.PauseMode
+	PauseMode string    `json:"pauseMode,omitempty"`
+	PausedAt  time.Time `json:"pausedAt,omitempty"`
+}
+
+// Partition is the backup of one Zeebe partition. CreatedAt is when its checkpoint was taken.
+type Partition struct {
+	ID                 int       `json:"id"`
+	CheckpointPosition int       `json:"checkpointPosition"`
+	CreatedAt          time.Time `json:"createdAt"`
+	SnapshotID         string    `json:"snapshotId"`
+	BrokerVersion      string    `json:"brokerVersion"`
+}
+
+// New starts the manifest of a backup.
+func New(backupID int64) *Manifest {
+	return &Manifest{
+		BackupID:    backupID,
+		CreatedAt:   time.Now().UTC(),
+		ToolVersion: toolVersion(),
+		Versions:    map[string]string{},
+	}
+}
+
+// Component returns the entry of the named component, or nil if it is not part of the backup.
+func (m *Manifest) Component(name string) *Component {
+	for i := range m.Components {
+		if m.Components[i].Name == name {
+			return &m.Components[i]
+		}
+	}
+	return nil
+}
+
+func toolVersion() string {
+	if ToolVersion != "" {
+		return ToolVersion
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return ""
+}
+
+func configMapName(backupID int64) string {
+	return fmt.Sprintf("c8backup-manifest-%d", backupID)
+}
+
+// Write signs the manifest and creates or replaces its ConfigMap. The signing key of the namespace is created on
+// first use.
+func Write(ctx context.Context, kubeClient kubernetes.Interface, namespace string, manifest *Manifest) error {
+	key, err := signingKey(ctx, kubeClient, namespace, true)
+	if err != nil {
+		return fmt.Errorf("unable to get the manifest signing key: %w", err)
+	}
+	manifest.Signature, err = manifest.sign(key)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName(manifest.BackupID),
+			Namespace: namespace,
+			Labels: map[string]string{
+				backupLabel: strconv.FormatInt(manifest.BackupID, 10),
+			},
+		},
+		Data: map[string]string{manifestKey: string(data)},
+	}
+	_, err = kubeClient.CoreV1().ConfigMaps(namespace).Update(ctx, configMap, metav1.UpdateOptions{FieldManager: "c8-backup"})
+	if apierrors.IsNotFound(err) {
+		_, err = kubeClient.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{FieldManager: "c8-backup"})
+	}
+	return err
+}
+
+// Read returns the manifest of the backup, or nil if there is none. It is not verified.
+func Read(ctx context.Context, kubeClient kubernetes.Interface, namespace string, backupID int64) (*Manifest, error) {
+	configMap, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, configMapName(backupID), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var manifest Manifest
+	err = json.Unmarshal([]byte(configMap.Data[manifestKey]), &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the manifest of backup %d: %w", backupID, err)
+	}
+	return &manifest, nil
+}
+
+// Verify checks the signature of the manifest against the signing key of the namespace it was read from.
+func Verify(ctx context.Context, kubeClient kubernetes.Interface, namespace string, manifest *Manifest) error {
+	if manifest.Signature == "" {
+		return fmt.Errorf("backup %d: %w", manifest.BackupID, ErrUnsigned)
+	}
+	key, err := signingKey(ctx, kubeClient, namespace, false)
+	if err != nil {
+		return fmt.Errorf("unable to get the manifest signing key: %w", err)
+	}
+	signature, err := manifest.sign(key)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(manifest.Signature)) {
+		return fmt.Errorf("backup %d: %w", manifest.BackupID, ErrInvalidSignature)
+	}
+	return nil
+}
+
+// sign is the hex HMAC-SHA256 of the manifest without its signature.
+func (m Manifest) sign(key []byte) (string, error) {
+	m.Signature = ""
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// signingKey reads the key from its Secret, creating a random one if there is none and create is set.
+func signingKey(ctx context.Context, kubeClient kubernetes.Interface, namespace string, create bool) ([]byte, error) {
+	secret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, keySecret, metav1.GetOptions{})
+	if err == nil {
+		if len(secret.Data[keyKey]) == 0 {
+			return nil, fmt.Errorf("secret %s has no %s", keySecret, keyKey)
+		}
+		return secret.Data[keyKey], nil
+	}
+	if !apierrors.IsNotFound(err) || !create {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = kubeClient.CoreV1().Secrets(namespace).Create(ctx, &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: keySecret, Namespace: namespace},
+		Data:       map[string][]byte{keyKey: key},
+	}, metav1.CreateOptions{FieldManager: "c8-backup"})
+	if apierrors.IsAlreadyExists(err) {
+		// Created concurrently by another backup
+		return signingKey(ctx, kubeClient, namespace, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,124 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const namespace = "camunda"
+
+func testManifest() *Manifest {
+	manifest := New(1679426843)
+	manifest.Versions["zeebe"] = "8.3.1"
+	manifest.Components = []Component{
+		{Name: "zeebe", State: component.StateCompleted, Duration: "1m0s"},
+		{Name: "operate", State: component.StateCompleted, Snapshots: []string{"camunda_operate_1679426843_8.3.1_part_1_of_6"}},
+	}
+	manifest.Zeebe = &Zeebe{
+		Partitions: []Partition{{ID: 1, CheckpointPosition: 42, CreatedAt: time.Now().UTC()}},
+		PauseMode:  "hard",
+	}
+	return manifest
+}
+
+func TestWriteRead(t *testing.T) {
+	ctx := context.Background()
+	kubeClient := fake.NewSimpleClientset()
+
+	written := testManifest()
+	if err := Write(ctx, kubeClient, namespace, written); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if written.Signature == "" {
+		t.Fatalf("Write() left the manifest unsigned")
+	}
+	// Writing again replaces the ConfigMap and keeps the key
+	if err := Write(ctx, kubeClient, namespace, written); err != nil {
+		t.Fatalf("Write() again error = %v", err)
+	}
+
+	read, err := Read(ctx, kubeClient, namespace, written.BackupID)
+	if err != nil || read == nil {
+		t.Fatalf("Read() = %v, %v", read, err)
+	}
+	if err := Verify(ctx, kubeClient, namespace, read); err != nil {
+		t.Fatalf("Verify() of the written manifest error = %v", err)
+	}
+
+	missing, err := Read(ctx, kubeClient, namespace, 1)
+	if err != nil || missing != nil {
+		t.Fatalf("Read() of a backup without manifest = %v, %v, want nil", missing, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(manifest *Manifest)
+		key     []byte
+		wantErr error
+		fails   bool
+	}{
+		{name: "unchanged", change: func(manifest *Manifest) {}},
+		{name: "state changed", change: func(manifest *Manifest) { manifest.Components[0].State = component.StateFailed }, wantErr: ErrInvalidSignature},
+		{name: "snapshot added", change: func(manifest *Manifest) {
+			manifest.Components[1].Snapshots = append(manifest.Components[1].Snapshots, "other")
+		}, wantErr: ErrInvalidSignature},
+		{name: "pause mode changed", change: func(manifest *Manifest) { manifest.Zeebe.PauseMode = "soft" }, wantErr: ErrInvalidSignature},
+		{name: "signature changed", change: func(manifest *Manifest) { manifest.Signature = "00" + manifest.Signature[2:] }, wantErr: ErrInvalidSignature},
+		{name: "unsigned", change: func(manifest *Manifest) { manifest.Signature = "" }, wantErr: ErrUnsigned},
+		{name: "other key", change: func(manifest *Manifest) {}, key: []byte("another key of another namespace"), wantErr: ErrInvalidSignature},
+		{name: "key without data", change: func(manifest *Manifest) {}, key: []byte{}, fails: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			kubeClient := fake.NewSimpleClientset(&corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: keySecret, Namespace: namespace},
+				Data:       map[string][]byte{keyKey: []byte("the key the manifests are signed with")},
+			})
+			manifest := testManifest()
+			if err := Write(ctx, kubeClient, namespace, manifest); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			test.change(manifest)
+			if test.key != nil {
+				secret, _ := kubeClient.CoreV1().Secrets(namespace).Get(ctx, keySecret, metav1.GetOptions{})
+				secret.Data[keyKey] = test.key
+				if _, err := kubeClient.CoreV1().Secrets(namespace).Update(ctx, secret, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("unable to change the key: %v", err)
+				}
+			}
+
+			err := Verify(ctx, kubeClient, namespace, manifest)
+			switch {
+			case test.fails:
+				if err == nil {
+					t.Fatalf("Verify() succeeded, want a failure")
+				}
+			case test.wantErr != nil:
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("Verify() error = %v, want %v", err, test.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("Verify() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutKey(t *testing.T) {
+	manifest := testManifest()
+	manifest.Signature = "00"
+	err := Verify(context.Background(), fake.NewSimpleClientset(), namespace, manifest)
+	if err == nil {
+		t.Fatalf("Verify() without a signing key succeeded")
+	}
+}
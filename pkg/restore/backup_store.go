@@ -0,0 +1,311 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	StoreS3         = "s3"
+	StoreGCS        = "gcs"
+	StoreAzure      = "azure"
+	StoreFilesystem = "filesystem"
+)
+
+const backupEnvPrefix = "ZEEBE_BROKER_DATA_BACKUP_"
+
+// BackupStore configures the Zeebe restore Job so that it can read the backup from where the brokers wrote it.
+// Apply is called with the restore Job's pod spec, the restore container is the first container.
+type BackupStore interface {
+	Type() string
+	Apply(spec *corev1.PodSpec)
+}
+
+// BackupStoreConfig is what the user passes on the command line. Empty fields are left as the Zeebe
+// StatefulSet configures them, since the restore Job starts from its env.
+type BackupStoreConfig struct {
+	Type           string
+	Bucket         string
+	BasePath       string
+	Region         string
+	Endpoint       string
+	AccountName    string
+	Secret         string
+	SecretKey      string
+	ServiceAccount string
+	ClaimName      string
+}
+
+// NewBackupStore returns the configured store, or nil if the store should be detected from the Zeebe StatefulSet.
+func NewBackupStore(config BackupStoreConfig) (BackupStore, error) {
+	switch strings.ToLower(config.Type) {
+	case "":
+		return nil, nil
+	case StoreS3:
+		return S3Store{config}, nil
+	case StoreGCS:
+		return GCSStore{config}, nil
+	case StoreAzure:
+		return AzureStore{config}, nil
+	case StoreFilesystem:
+		if config.BasePath == "" || config.ClaimName == "" {
+			return nil, fmt.Errorf("the filesystem backup store needs a base path and a claim name")
+		}
+		return FilesystemStore{config}, nil
+	default:
+		return nil, fmt.Errorf("backup store %q not supported, use one of %s, %s, %s, %s", config.Type, StoreS3, StoreGCS, StoreAzure, StoreFilesystem)
+	}
+}
+
+// S3Store authenticates with an access key Secret (keys accessKey and secretKey) or, for IRSA, a service account.
+type S3Store struct {
+	config BackupStoreConfig
+}
+
+func (s S3Store) Type() string {
+	return StoreS3
+}
+
+func (s S3Store) Apply(spec *corev1.PodSpec) {
+	container := &spec.Containers[0]
+	setEnv(container, backupEnvPrefix+"STORE", "S3")
+	setEnv(container, backupEnvPrefix+"S3_BUCKETNAME", s.config.Bucket)
+	setEnv(container, backupEnvPrefix+"S3_BASEPATH", s.config.BasePath)
+	setEnv(container, backupEnvPrefix+"S3_REGION", s.config.Region)
+	setEnv(container, backupEnvPrefix+"S3_ENDPOINT", s.config.Endpoint)
+	if s.config.Secret != "" {
+		setSecretEnv(container, backupEnvPrefix+"S3_ACCESSKEY", s.config.Secret, "accessKey")
+		setSecretEnv(container, backupEnvPrefix+"S3_SECRETKEY", s.config.Secret, "secretKey")
+	}
+	setServiceAccount(spec, s.config.ServiceAccount)
+}
+
+// GCSStore authenticates with a service account key Secret or, for workload identity, a service account.
+type GCSStore struct {
+	config BackupStoreConfig
+}
+
+const gcsKeyMountPath = "/usr/local/share/gcs-key"
+
+func (g GCSStore) Type() string {
+	return StoreGCS
+}
+
+func (g GCSStore) Apply(spec *corev1.PodSpec) {
+	container := &spec.Containers[0]
+	setEnv(container, backupEnvPrefix+"STORE", "GCS")
+	setEnv(container, backupEnvPrefix+"GCS_BUCKETNAME", g.config.Bucket)
+	setEnv(container, backupEnvPrefix+"GCS_BASEPATH", g.config.BasePath)
+	if g.config.Secret != "" {
+		keyFile := g.config.SecretKey
+		if keyFile == "" {
+			keyFile = "key.json"
+		}
+		addSecretVolume(spec, "gcs-backup-key", g.config.Secret, gcsKeyMountPath)
+		setEnv(container, "GOOGLE_APPLICATION_CREDENTIALS", path.Join(gcsKeyMountPath, keyFile))
+	}
+	setServiceAccount(spec, g.config.ServiceAccount)
+}
+
+// AzureStore authenticates with an account key Secret (key accountKey) or, for workload identity, a service account.
+type AzureStore struct {
+	config BackupStoreConfig
+}
+
+func (a AzureStore) Type() string {
+	return StoreAzure
+}
+
+func (a AzureStore) Apply(spec *corev1.PodSpec) {
+	container := &spec.Containers[0]
+	setEnv(container, backupEnvPrefix+"STORE", "AZURE")
+	setEnv(container, backupEnvPrefix+"AZURE_BASEPATH", a.config.BasePath)
+	setEnv(container, backupEnvPrefix+"AZURE_ENDPOINT", a.config.Endpoint)
+	setEnv(container, backupEnvPrefix+"AZURE_ACCOUNTNAME", a.config.AccountName)
+	if a.config.Secret != "" {
+		key := a.config.SecretKey
+		if key == "" {
+			key = "accountKey"
+		}
+		setSecretEnv(container, backupEnvPrefix+"AZURE_ACCOUNTKEY", a.config.Secret, key)
+	}
+	setServiceAccount(spec, a.config.ServiceAccount)
+}
+
+// FilesystemStore mounts the claim holding the backups at the base path.
+type FilesystemStore struct {
+	config BackupStoreConfig
+}
+
+func (f FilesystemStore) Type() string {
+	return StoreFilesystem
+}
+
+func (f FilesystemStore) Apply(spec *corev1.PodSpec) {
+	container := &spec.Containers[0]
+	setEnv(container, backupEnvPrefix+"STORE", "FILESYSTEM")
+	setEnv(container, backupEnvPrefix+"FILESYSTEM_BASEPATH", f.config.BasePath)
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: "backup-store",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: f.config.ClaimName,
+				ReadOnly:  true,
+			},
+		},
+	})
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "backup-store",
+		MountPath: f.config.BasePath,
+		ReadOnly:  true,
+	})
+}
+
+// inheritedStore reuses the backup store configuration of the Zeebe brokers: the env of the store, which the restore
+// Job already has from the brokers, the volumes holding credentials or backups and the service account.
+type inheritedStore struct {
+	storeType      string
+	env            []corev1.EnvVar
+	volumes        []corev1.Volume
+	volumeMounts   []corev1.VolumeMount
+	envFrom        []corev1.EnvFromSource
+	serviceAccount string
+}
+
+// DetectZeebeBackupStore detects the backup store of the Zeebe StatefulSet of the namespace, which is detected itself
+// if statefulSet is empty.
+func DetectZeebeBackupStore(ctx context.Context, kubeClient kubernetes.Interface, namespace, statefulSet string) (BackupStore, error) {
+	sts, err := findZeebeStatefulSet(ctx, kubeClient, namespace, statefulSet)
+	if err != nil {
+		return nil, err
+	}
+	return DetectBackupStore(sts)
+}
+
+// DetectBackupStore reads the ZEEBE_BROKER_DATA_BACKUP_* env of the Zeebe StatefulSet.
+func DetectBackupStore(sts *apps.StatefulSet) (BackupStore, error) {
+	podSpec := sts.Spec.Template.Spec
+	container := podSpec.Containers[0]
+	storeType := strings.ToLower(envValue(container, backupEnvPrefix+"STORE"))
+	if storeType == "" || storeType == "none" {
+		return nil, fmt.Errorf("statefulset %s has no %sSTORE configured, pass --backup-store", sts.Name, backupEnvPrefix)
+	}
+
+	store := inheritedStore{
+		storeType:      storeType,
+		envFrom:        container.EnvFrom,
+		serviceAccount: podSpec.ServiceAccountName,
+	}
+	for _, env := range container.Env {
+		if strings.HasPrefix(env.Name, backupEnvPrefix) || env.Name == "GOOGLE_APPLICATION_CREDENTIALS" {
+			store.env = append(store.env, env)
+		}
+	}
+
+	// Paths the store reads from: credential files and the filesystem store's base path
+	var paths []string
+	if credentials := envValue(container, "GOOGLE_APPLICATION_CREDENTIALS"); credentials != "" {
+		paths = append(paths, credentials)
+	}
+	if basePath := envValue(container, backupEnvPrefix+"FILESYSTEM_BASEPATH"); storeType == StoreFilesystem && basePath != "" {
+		paths = append(paths, basePath)
+	}
+	for _, mount := range container.VolumeMounts {
+		for _, p := range paths {
+			if p == mount.MountPath || strings.HasPrefix(p, strings.TrimSuffix(mount.MountPath, "/")+"/") {
+				store.volumeMounts = append(store.volumeMounts, mount)
+				break
+			}
+		}
+	}
+	for _, mount := range store.volumeMounts {
+		for _, volume := range podSpec.Volumes {
+			if volume.Name == mount.Name {
+				store.volumes = append(store.volumes, volume)
+			}
+		}
+	}
+	return store, nil
+}
+
+func (i inheritedStore) Type() string {
+	return i.storeType
+}
+
+func (i inheritedStore) Apply(spec *corev1.PodSpec) {
+	container := &spec.Containers[0]
+	for _, env := range i.env {
+		setEnvVar(container, env)
+	}
+	container.EnvFrom = append(container.EnvFrom, i.envFrom...)
+	container.VolumeMounts = append(container.VolumeMounts, i.volumeMounts...)
+	spec.Volumes = append(spec.Volumes, i.volumes...)
+	setServiceAccount(spec, i.serviceAccount)
+}
+
+func envValue(container corev1.Container, name string) string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// setEnv replaces or adds the env var. Empty values are skipped, so the broker's configuration stays.
+func setEnv(container *corev1.Container, name, value string) {
+	if value == "" {
+		return
+	}
+	setEnvVar(container, corev1.EnvVar{Name: name, Value: value})
+}
+
+func setSecretEnv(container *corev1.Container, name, secret, key string) {
+	setEnvVar(container, corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+				Key:                  key,
+			},
+		},
+	})
+}
+
+func setEnvVar(container *corev1.Container, envVar corev1.EnvVar) {
+	for i, env := range container.Env {
+		if env.Name == envVar.Name {
+			container.Env[i] = envVar
+			return
+		}
+	}
+	container.Env = append(container.Env, envVar)
+}
+
+func addSecretVolume(spec *corev1.PodSpec, name, secret, mountPath string) {
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: name,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: secret,
+			},
+		},
+	})
+	spec.Containers[0].VolumeMounts = append(spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+		Name:      name,
+		MountPath: mountPath,
+		ReadOnly:  true,
+	})
+}
+
+func setServiceAccount(spec *corev1.PodSpec, serviceAccount string) {
+	if serviceAccount != "" {
+		spec.ServiceAccountName = serviceAccount
+	}
+}
@@ -0,0 +1,248 @@
+package restore
+
+import (
+	"time"
+
+	"c8backup/pkg/backup-client/postgres"
+	"k8s.io/client-go/kubernetes"
+)
+
+type RestoreDefinition struct {
+	namespace            string
+	backupID             int64
+	elasticURL           string
+	zeebeURL             string
+	zeebeStatefulSet     string
+	operateURL           string
+	tasklistURL          string
+	optimizeURL          string
+	backupRepositoryName string
+	components           []string
+	indexPrefixes        map[string]string
+	wipeAll              bool
+	backupStore          BackupStore
+	jobTimeout           time.Duration
+	jobOverrides         JobOverrides
+	healthTimeout        time.Duration
+	appSelector          string
+	argoCDNamespace      string
+	fresh                bool
+	sourceKubeClient     *kubernetes.Clientset
+	sourceNamespace      string
+	renamePrefix         string
+	verifyOperateURL     string
+	keep                 bool
+	databases            []postgres.Config
+	resources            map[string]string
+	ignoreVersionCheck   bool
+	ignoreManifest       bool
+	deepVerify           bool
+	dryRun               bool
+	planFile             string
+	applyPlanFile        string
+	resume               bool
+	abort                bool
+}
+
+type RestoreDefinitionBuilder struct {
+	restoreDefinition RestoreDefinition
+}
+
+func (b RestoreDefinitionBuilder) Namespace(namespace string) RestoreDefinitionBuilder {
+	b.restoreDefinition.namespace = namespace
+	return b
+}
+
+func (b RestoreDefinitionBuilder) BackupID(id int64) RestoreDefinitionBuilder {
+	b.restoreDefinition.backupID = id
+	return b
+}
+
+func (b RestoreDefinitionBuilder) Operate(url string) RestoreDefinitionBuilder {
+	b.restoreDefinition.operateURL = url
+	return b
+}
+
+func (b RestoreDefinitionBuilder) Optimize(url string) RestoreDefinitionBuilder {
+	b.restoreDefinition.optimizeURL = url
+	return b
+}
+
+func (b RestoreDefinitionBuilder) Tasklist(url string) RestoreDefinitionBuilder {
+	b.restoreDefinition.tasklistURL = url
+	return b
+}
+
+// Zeebe is the mgmt endpoint used to check the Zeebe backup against the topology of the brokers.
+func (b RestoreDefinitionBuilder) Zeebe(url string) RestoreDefinitionBuilder {
+	b.restoreDefinition.zeebeURL = url
+	return b
+}
+
+// ZeebeStatefulSet is the name of the broker StatefulSet. Without it the StatefulSet is detected.
+func (b RestoreDefinitionBuilder) ZeebeStatefulSet(name string) RestoreDefinitionBuilder {
+	b.restoreDefinition.zeebeStatefulSet = name
+	return b
+}
+
+func (b RestoreDefinitionBuilder) Elastic(url, snapshotRepositoryName string) RestoreDefinitionBuilder {
+	b.restoreDefinition.elasticURL = url
+	b.restoreDefinition.backupRepositoryName = snapshotRepositoryName
+	return b
+}
+
+// Components limits the restore to a subset of AllComponents.
+func (b RestoreDefinitionBuilder) Components(components []string) RestoreDefinitionBuilder {
+	b.restoreDefinition.components = components
+	return b
+}
+
+// IndexPrefixes overrides the DefaultIndexPrefixes per component.
+func (b RestoreDefinitionBuilder) IndexPrefixes(prefixes map[string]string) RestoreDefinitionBuilder {
+	b.restoreDefinition.indexPrefixes = prefixes
+	return b
+}
+
+// WipeAll deletes every index in the cluster instead of only the Camunda ones.
+func (b RestoreDefinitionBuilder) WipeAll(wipeAll bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.wipeAll = wipeAll
+	return b
+}
+
+// BackupStore configures where the Zeebe restore Jobs read the backup from. Without it the store is
+// detected from the Zeebe StatefulSet.
+func (b RestoreDefinitionBuilder) BackupStore(store BackupStore) RestoreDefinitionBuilder {
+	b.restoreDefinition.backupStore = store
+	return b
+}
+
+// JobTimeout limits how long the Zeebe delete and restore Jobs may run. Default: DefaultJobTimeout.
+func (b RestoreDefinitionBuilder) JobTimeout(timeout time.Duration) RestoreDefinitionBuilder {
+	b.restoreDefinition.jobTimeout = timeout
+	return b
+}
+
+// JobOverrides replaces what the delete and restore Jobs inherit from the Zeebe StatefulSet.
+func (b RestoreDefinitionBuilder) JobOverrides(overrides JobOverrides) RestoreDefinitionBuilder {
+	b.restoreDefinition.jobOverrides = overrides
+	return b
+}
+
+// HealthTimeout is how long the restore waits for each component to become healthy after scaling it up.
+func (b RestoreDefinitionBuilder) HealthTimeout(timeout time.Duration) RestoreDefinitionBuilder {
+	b.restoreDefinition.healthTimeout = timeout
+	return b
+}
+
+// AppSelector is the label selector of the Deployments that are scaled down. Default: DefaultAppSelector
+func (b RestoreDefinitionBuilder) AppSelector(selector string) RestoreDefinitionBuilder {
+	b.restoreDefinition.appSelector = selector
+	return b
+}
+
+// ArgoCDNamespace is where the Argo CD Applications managing the apps live. Default: DefaultArgoCDNamespace
+func (b RestoreDefinitionBuilder) ArgoCDNamespace(namespace string) RestoreDefinitionBuilder {
+	b.restoreDefinition.argoCDNamespace = namespace
+	return b
+}
+
+// Fresh restores into an empty or freshly installed namespace: apps that don't run are not scaled and missing
+// Zeebe PVCs are created.
+func (b RestoreDefinitionBuilder) Fresh(fresh bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.fresh = fresh
+	return b
+}
+
+// Source is the installation the backup was taken from. In fresh mode its Zeebe StatefulSet is the template
+// for the PVCs and the restore Jobs, if the target namespace has none yet.
+func (b RestoreDefinitionBuilder) Source(kubeClient *kubernetes.Clientset, namespace string) RestoreDefinitionBuilder {
+	b.restoreDefinition.sourceKubeClient = kubeClient
+	b.restoreDefinition.sourceNamespace = namespace
+	return b
+}
+
+// RenamePrefix is put in front of the indices verify-restore restores. Default: verify-<backup id>-
+func (b RestoreDefinitionBuilder) RenamePrefix(prefix string) RestoreDefinitionBuilder {
+	b.restoreDefinition.renamePrefix = prefix
+	return b
+}
+
+// VerifyOperate is the mgmt endpoint of an Operate installed in the verify-restore namespace.
+func (b RestoreDefinitionBuilder) VerifyOperate(url string) RestoreDefinitionBuilder {
+	b.restoreDefinition.verifyOperateURL = url
+	return b
+}
+
+// Keep leaves what verify-restore created in place, to look into a failed verification.
+func (b RestoreDefinitionBuilder) Keep(keep bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.keep = keep
+	return b
+}
+
+// DryRun only resolves and prints the restore plan without touching the cluster.
+func (b RestoreDefinitionBuilder) DryRun(dryRun bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.dryRun = dryRun
+	return b
+}
+
+// PlanFile saves the resolved plan to path and stops, so it can be reviewed and applied later.
+func (b RestoreDefinitionBuilder) PlanFile(path string) RestoreDefinitionBuilder {
+	b.restoreDefinition.planFile = path
+	return b
+}
+
+// ApplyPlan runs a plan previously saved with PlanFile instead of resolving a new one.
+func (b RestoreDefinitionBuilder) ApplyPlan(path string) RestoreDefinitionBuilder {
+	b.restoreDefinition.applyPlanFile = path
+	return b
+}
+
+// Resume continues the unfinished restore recorded in the journal of the namespace.
+func (b RestoreDefinitionBuilder) Resume(resume bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.resume = resume
+	return b
+}
+
+// Abort scales the apps of an unfinished restore back to their recorded replicas.
+func (b RestoreDefinitionBuilder) Abort(abort bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.abort = abort
+	return b
+}
+
+// Databases are the PostgreSQL databases of the components restored from pg_dump dumps, e.g. Identity's Keycloak.
+func (b RestoreDefinitionBuilder) Databases(databases []postgres.Config) RestoreDefinitionBuilder {
+	b.restoreDefinition.databases = databases
+	return b
+}
+
+// Resources are the label selectors of the Secrets and ConfigMaps kept per component, e.g. of Connectors.
+func (b RestoreDefinitionBuilder) Resources(selectors map[string]string) RestoreDefinitionBuilder {
+	b.restoreDefinition.resources = selectors
+	return b
+}
+
+// IgnoreVersionCheck restores even if the backup was taken on another Camunda version.
+func (b RestoreDefinitionBuilder) IgnoreVersionCheck(ignore bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.ignoreVersionCheck = ignore
+	return b
+}
+
+// IgnoreManifest asks the components for their backups instead of trusting the manifest of the backup.
+func (b RestoreDefinitionBuilder) IgnoreManifest(ignore bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.ignoreManifest = ignore
+	return b
+}
+
+// DeepVerify makes verify read back every snapshot of the repository with the integrity check of Elasticsearch.
+func (b RestoreDefinitionBuilder) DeepVerify(deep bool) RestoreDefinitionBuilder {
+	b.restoreDefinition.deepVerify = deep
+	return b
+}
+
+func (b RestoreDefinitionBuilder) Build() RestoreDefinition {
+	return b.restoreDefinition
+}
+
+func NewRestoreDefinitionBuilder() RestoreDefinitionBuilder {
+	return RestoreDefinitionBuilder{}
+}
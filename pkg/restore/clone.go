@@ -0,0 +1,295 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const verifyLabel = "c8backup/verify"
+
+const exporterEnvPrefix = "ZEEBE_BROKER_EXPORTERS_"
+
+// cloneStatefulSet copies the broker StatefulSet of the source installation into the scratch namespace. Addresses
+// of the source namespace in the env are rewritten, the brokers take no backups, and exporters configured in the
+// env write to indices with the given prefix, so the clone never touches the data of the source installation.
+// It returns warnings about configuration it can't redirect.
+func cloneStatefulSet(source *apps.StatefulSet, namespace string, replicas int32, indexPrefix string, backupID int64) (*apps.StatefulSet, []string) {
+	clone := &apps.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: namespace,
+			Labels:    verifyLabels(source.Labels, backupID),
+		},
+		Spec: *source.Spec.DeepCopy(),
+	}
+	clone.Spec.Replicas = &replicas
+	clone.Spec.Template.Labels = verifyLabels(clone.Spec.Template.Labels, backupID)
+	// Service accounts of the source namespace don't exist here, and without backups the brokers need no credentials
+	clone.Spec.Template.Spec.ServiceAccountName = ""
+
+	for i := range clone.Spec.VolumeClaimTemplates {
+		template := &clone.Spec.VolumeClaimTemplates[i]
+		template.Labels = verifyLabels(template.Labels, backupID)
+	}
+
+	var warnings []string
+	container := &clone.Spec.Template.Spec.Containers[0]
+	for i, env := range container.Env {
+		container.Env[i].Value = strings.ReplaceAll(env.Value, "."+source.Namespace+".svc", "."+namespace+".svc")
+	}
+	setEnvVar(container, corev1.EnvVar{Name: backupEnvPrefix + "STORE", Value: "NONE"})
+	exporters := searchExporters(*container)
+	for _, name := range exporters {
+		setEnvVar(container, corev1.EnvVar{Name: exporterEnvPrefix + name + "_ARGS_INDEX_PREFIX", Value: indexPrefix + "zeebe-record"})
+	}
+	if len(exporters) == 0 && len(configMapNames(clone)) > 0 {
+		warnings = append(warnings, fmt.Sprintf("no elasticsearch exporter configured in the env of %s, exporters configured in its ConfigMaps %v are not redirected", source.Name, configMapNames(clone)))
+	}
+	return clone, warnings
+}
+
+// searchExporters returns the names of the Elasticsearch and OpenSearch exporters configured in the env.
+func searchExporters(container corev1.Container) []string {
+	var names []string
+	for _, env := range container.Env {
+		if !strings.HasPrefix(env.Name, exporterEnvPrefix) || !strings.HasSuffix(env.Name, "_CLASSNAME") {
+			continue
+		}
+		className := strings.ToLower(env.Value)
+		if strings.Contains(className, "elasticsearch") || strings.Contains(className, "opensearch") {
+			names = append(names, strings.TrimSuffix(strings.TrimPrefix(env.Name, exporterEnvPrefix), "_CLASSNAME"))
+		}
+	}
+	return names
+}
+
+// cloneService copies the governing Service of the StatefulSet, the brokers find each other through it.
+func cloneService(source *corev1.Service, namespace string, backupID int64) *corev1.Service {
+	clone := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      source.Name,
+			Namespace: namespace,
+			Labels:    verifyLabels(source.Labels, backupID),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports:                    source.Spec.Ports,
+			Selector:                 source.Spec.Selector,
+			Type:                     corev1.ServiceTypeClusterIP,
+			PublishNotReadyAddresses: source.Spec.PublishNotReadyAddresses,
+		},
+	}
+	if source.Spec.ClusterIP == corev1.ClusterIPNone {
+		clone.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	for i := range clone.Spec.Ports {
+		clone.Spec.Ports[i].NodePort = 0
+	}
+	return clone
+}
+
+// configMapNames returns the ConfigMaps mounted by the pod template.
+func configMapNames(sts *apps.StatefulSet) []string {
+	var names []string
+	for _, volume := range sts.Spec.Template.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			names = append(names, volume.ConfigMap.Name)
+		}
+	}
+	return names
+}
+
+// podReferences are the ConfigMaps and Secrets a pod spec refers to, with whether they are required, and its
+// ServiceAccount.
+type podReferences struct {
+	configMaps     map[string]bool
+	secrets        map[string]bool
+	serviceAccount string
+}
+
+func referencesOf(spec corev1.PodSpec) podReferences {
+	refs := podReferences{configMaps: map[string]bool{}, secrets: map[string]bool{}, serviceAccount: spec.ServiceAccountName}
+	add := func(names map[string]bool, name string, optional *bool) {
+		names[name] = names[name] || optional == nil || !*optional
+	}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			add(refs.configMaps, volume.ConfigMap.Name, volume.ConfigMap.Optional)
+		}
+		if volume.Secret != nil {
+			add(refs.secrets, volume.Secret.SecretName, volume.Secret.Optional)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					add(refs.configMaps, source.ConfigMap.Name, source.ConfigMap.Optional)
+				}
+				if source.Secret != nil {
+					add(refs.secrets, source.Secret.Name, source.Secret.Optional)
+				}
+			}
+		}
+	}
+	for _, container := range append(spec.InitContainers, spec.Containers...) {
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.ConfigMapKeyRef != nil {
+				add(refs.configMaps, env.ValueFrom.ConfigMapKeyRef.Name, env.ValueFrom.ConfigMapKeyRef.Optional)
+			}
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil {
+				add(refs.secrets, env.ValueFrom.SecretKeyRef.Name, env.ValueFrom.SecretKeyRef.Optional)
+			}
+		}
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add(refs.configMaps, envFrom.ConfigMapRef.Name, envFrom.ConfigMapRef.Optional)
+			}
+			if envFrom.SecretRef != nil {
+				add(refs.secrets, envFrom.SecretRef.Name, envFrom.SecretRef.Optional)
+			}
+		}
+	}
+	for _, pullSecret := range spec.ImagePullSecrets {
+		add(refs.secrets, pullSecret.Name, nil)
+	}
+	return refs
+}
+
+// copyReferences copies the ConfigMaps, Secrets and the ServiceAccount the pods refer to from the source namespace,
+// before anything is started there, so that a missing one fails the verification up front. Existing ones are kept,
+// missing optional ones are skipped. The ServiceAccount keeps its annotations, they bind it to cloud identities like
+// the one the backup store is read with, but not its RBAC bindings.
+func copyReferences(ctx context.Context, sourceKubeClient, kubeClient *kubernetes.Clientset, sourceNamespace, namespace string, refs podReferences, backupID int64) error {
+	for name, required := range refs.configMaps {
+		source, err := sourceKubeClient.CoreV1().ConfigMaps(sourceNamespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && !required {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		_, err = kubeClient.CoreV1().ConfigMaps(namespace).Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    verifyLabels(source.Labels, backupID),
+			},
+			Data:       source.Data,
+			BinaryData: source.BinaryData,
+		}, metav1.CreateOptions{FieldManager: "c8-backup"})
+		err = copied("configmap", name, err)
+		if err != nil {
+			return err
+		}
+	}
+	for name, required := range refs.secrets {
+		source, err := sourceKubeClient.CoreV1().Secrets(sourceNamespace).Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) && !required {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if source.Type == corev1.SecretTypeServiceAccountToken {
+			return fmt.Errorf("secret %s is the token of a serviceaccount of namespace %s, it can't be copied", name, sourceNamespace)
+		}
+		_, err = kubeClient.CoreV1().Secrets(namespace).Create(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    verifyLabels(source.Labels, backupID),
+			},
+			Type: source.Type,
+			Data: source.Data,
+		}, metav1.CreateOptions{FieldManager: "c8-backup"})
+		err = copied("secret", name, err)
+		if err != nil {
+			return err
+		}
+	}
+	// Every namespace has its default ServiceAccount
+	if refs.serviceAccount == "" || refs.serviceAccount == "default" {
+		return nil
+	}
+	source, err := sourceKubeClient.CoreV1().ServiceAccounts(sourceNamespace).Get(ctx, refs.serviceAccount, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = kubeClient.CoreV1().ServiceAccounts(namespace).Create(ctx, &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        refs.serviceAccount,
+			Namespace:   namespace,
+			Labels:      verifyLabels(source.Labels, backupID),
+			Annotations: source.Annotations,
+		},
+	}, metav1.CreateOptions{FieldManager: "c8-backup"})
+	return copied("serviceaccount", refs.serviceAccount, err)
+}
+
+func copied(kind, name string, err error) error {
+	if apierrors.IsAlreadyExists(err) {
+		fmt.Println(kind, "already exists", name)
+		return nil
+	}
+	if err == nil {
+		fmt.Println("copied", kind, name)
+	}
+	return err
+}
+
+// deleteClone removes what verify-restore created in the scratch namespace: the labeled StatefulSets, Services,
+// ConfigMaps, Secrets, ServiceAccounts and PVCs.
+func deleteClone(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string, backupID int64) []error {
+	var errors []error
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%d", verifyLabel, backupID)}
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	err := kubeClient.AppsV1().StatefulSets(namespace).DeleteCollection(ctx, deleteOptions, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	services, err := kubeClient.CoreV1().Services(namespace).List(ctx, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	} else {
+		// Services don't support deletecollection
+		for _, service := range services.Items {
+			err = kubeClient.CoreV1().Services(namespace).Delete(ctx, service.Name, deleteOptions)
+			if err != nil && !apierrors.IsNotFound(err) {
+				errors = append(errors, err)
+			}
+		}
+	}
+	err = kubeClient.CoreV1().ConfigMaps(namespace).DeleteCollection(ctx, deleteOptions, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	err = kubeClient.CoreV1().Secrets(namespace).DeleteCollection(ctx, deleteOptions, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	err = kubeClient.CoreV1().ServiceAccounts(namespace).DeleteCollection(ctx, deleteOptions, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	err = kubeClient.CoreV1().PersistentVolumeClaims(namespace).DeleteCollection(ctx, deleteOptions, listOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+	return errors
+}
+
+func verifyLabels(labels map[string]string, backupID int64) map[string]string {
+	result := map[string]string{}
+	for key, value := range labels {
+		result[key] = value
+	}
+	result[verifyLabel] = fmt.Sprint(backupID)
+	return result
+}
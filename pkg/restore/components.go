@@ -0,0 +1,223 @@
+package restore
+
+import (
+	"fmt"
+	"strings"
+
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/backup-client/webapps"
+	"c8backup/pkg/backup-client/zeebe"
+	apps "k8s.io/api/apps/v1"
+)
+
+const ComponentZeebe = "zeebe"
+
+// platformComponents keep their data in Zeebe and Elasticsearch, restoring all of them stops every app.
+var platformComponents = []string{ComponentZeebe, webapps.OperateApp, webapps.TasklistApp, webapps.OptimizeApp}
+
+// AllComponents are the platform components plus the ones added with RegisterComponent.
+var AllComponents = append([]string{}, platformComponents...)
+
+// dependentApps lists the values of the app.kubernetes.io/component label of the Deployments that have to be
+// stopped while a component is restored. The webapps import the Zeebe records, so they depend on Zeebe.
+var dependentApps = map[string][]string{
+	ComponentZeebe:      {"zeebe-gateway", webapps.OperateApp, webapps.TasklistApp, webapps.OptimizeApp},
+	webapps.OperateApp:  {webapps.OperateApp},
+	webapps.TasklistApp: {webapps.TasklistApp},
+	webapps.OptimizeApp: {webapps.OptimizeApp},
+}
+
+// DefaultIndexPrefixes are the prefixes of the indices each component writes to Elasticsearch.
+var DefaultIndexPrefixes = map[string]string{
+	ComponentZeebe:      "zeebe-record",
+	webapps.OperateApp:  "operate-",
+	webapps.TasklistApp: "tasklist-",
+	webapps.OptimizeApp: "optimize-",
+}
+
+// componentRequirements names the endpoints restoring a component needs. The webapps find their snapshots through
+// their mgmt endpoint. Zeebe restores its PVCs through Kubernetes and only needs Elasticsearch for the records of its
+// exporter, so it can be restored without Elasticsearch if the exporter is disabled.
+var componentRequirements = map[string][]string{
+	ComponentZeebe:      nil,
+	webapps.OperateApp:  {webapps.OperateApp, endpointElastic},
+	webapps.TasklistApp: {webapps.TasklistApp, endpointElastic},
+	webapps.OptimizeApp: {webapps.OptimizeApp, endpointElastic},
+}
+
+const endpointElastic = "elastic"
+
+// endpoint returns the URL of the definition the requirement names.
+func (d RestoreDefinition) endpoint(name string) string {
+	switch name {
+	case webapps.OperateApp:
+		return d.operateURL
+	case webapps.TasklistApp:
+		return d.tasklistURL
+	case webapps.OptimizeApp:
+		return d.optimizeURL
+	case endpointElastic:
+		return d.elasticURL
+	default:
+		if database, ok := d.database(strings.TrimSuffix(name, databaseSuffix)); ok {
+			return database.Address
+		}
+		return d.resources[strings.TrimSuffix(name, selectorSuffix)]
+	}
+}
+
+// configuredComponents returns the components chosen with --components or, without it, every component whose
+// endpoints are configured.
+func configuredComponents(definition RestoreDefinition) []string {
+	if len(definition.components) > 0 {
+		return definition.components
+	}
+	var components []string
+	for _, component := range AllComponents {
+		if checkRequirements(definition, component) == nil {
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+func checkRequirements(definition RestoreDefinition, component string) error {
+	for _, requirement := range componentRequirements[component] {
+		if definition.endpoint(requirement) == "" {
+			return fmt.Errorf("restoring %s needs --%s", component, requirement)
+		}
+	}
+	return nil
+}
+
+// backupClients returns the backup clients of the given components that have an endpoint. The backup of Zeebe is
+// the one of the brokers plus the snapshot of its exported records.
+func backupClients(definition RestoreDefinition, components []string) []component.Component {
+	var clients []component.Component
+	for _, name := range components {
+		switch name {
+		case ComponentZeebe:
+			if definition.zeebeURL != "" {
+				clients = append(clients, zeebeBackup.NewZeebeClient(definition.zeebeURL))
+			}
+			if definition.elasticURL != "" {
+				elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
+				clients = append(clients, elastic.NewZeebeRecords(elasticClient, DefaultIndexPrefixes[ComponentZeebe]+"*"))
+			}
+		case webapps.OperateApp, webapps.TasklistApp, webapps.OptimizeApp:
+			if definition.endpoint(name) != "" {
+				client, _ := webapps.NewBackupClient(name, definition.endpoint(name))
+				clients = append(clients, client)
+			}
+		default:
+			if client := registeredClient(definition, name); client != nil {
+				clients = append(clients, client)
+			}
+		}
+	}
+	return clients
+}
+
+// ParseComponents validates the components passed by the user. No components means all configured ones.
+func ParseComponents(components []string) ([]string, error) {
+	if len(components) == 0 {
+		return nil, nil
+	}
+	var parsed []string
+	for _, component := range components {
+		component = strings.ToLower(strings.TrimSpace(component))
+		if _, ok := dependentApps[component]; !ok {
+			return nil, fmt.Errorf("unknown component %q, supported are %v", component, AllComponents)
+		}
+		if !contains(parsed, component) {
+			parsed = append(parsed, component)
+		}
+	}
+	return parsed, nil
+}
+
+func allComponents(components []string) bool {
+	for _, component := range platformComponents {
+		if !contains(components, component) {
+			return false
+		}
+	}
+	return true
+}
+
+// dependsOn reports whether the deployment has to be stopped to restore the components. It matches on the
+// component label set by the Camunda Helm chart and falls back to the deployment name.
+func dependsOn(deployment apps.Deployment, components []string) bool {
+	label := deployment.Labels["app.kubernetes.io/component"]
+	for _, component := range components {
+		for _, app := range dependentApps[component] {
+			if label == app || (label == "" && strings.Contains(deployment.Name, app)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// componentOf returns the app.kubernetes.io/component label of the deployment, or the app its name contains.
+func componentOf(deployment apps.Deployment) string {
+	if label := deployment.Labels["app.kubernetes.io/component"]; label != "" {
+		return label
+	}
+	for _, app := range dependentApps[ComponentZeebe] {
+		if strings.Contains(deployment.Name, app) {
+			return app
+		}
+	}
+	return ""
+}
+
+// consistencyWarnings explains how restoring only some components leaves the platform inconsistent.
+func consistencyWarnings(components []string) []string {
+	if allComponents(components) {
+		return nil
+	}
+	var warnings []string
+	var webappsLeft []string
+	for _, app := range []string{webapps.OperateApp, webapps.TasklistApp, webapps.OptimizeApp} {
+		if !contains(components, app) {
+			webappsLeft = append(webappsLeft, app)
+		}
+	}
+	if contains(components, ComponentZeebe) && len(webappsLeft) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%v keep data that is newer than the restored Zeebe state, "+
+			"they will show process instances that no longer exist in Zeebe", webappsLeft))
+	}
+	if !contains(components, ComponentZeebe) {
+		for _, component := range components {
+			if _, ok := DefaultIndexPrefixes[component]; !ok {
+				continue
+			}
+			warnings = append(warnings, fmt.Sprintf("%s is restored to an older state than Zeebe and re-imports the Zeebe records "+
+				"still in Elasticsearch, records that were already cleaned up are missing", component))
+		}
+	}
+	return warnings
+}
+
+// indexPrefixes merges the prefixes configured by the user into the defaults.
+func indexPrefixes(overrides map[string]string) map[string]string {
+	prefixes := map[string]string{}
+	for component, prefix := range DefaultIndexPrefixes {
+		prefixes[component] = prefix
+	}
+	for component, prefix := range overrides {
+		prefixes[component] = prefix
+	}
+	return prefixes
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+
+	"c8backup/pkg/backup-client/postgres"
+	"c8backup/pkg/backup-client/resources"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+func (d RestoreDefinition) database(name string) (postgres.Config, bool) {
+	for _, database := range d.databases {
+		if database.Name == name {
+			return database, true
+		}
+	}
+	return postgres.Config{}, false
+}
+
+// planComponents adds the StatefulSets of the registered components, e.g. Keycloak, which caches what is in its
+// database, and the pg_restore Jobs of the restored databases to the plan.
+func planComponents(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition, plan *Plan) error {
+	for _, componentType := range componentTypes {
+		if componentType.StatefulSets == "" || !contains(plan.Components, componentType.Name) {
+			continue
+		}
+		statefulSets, err := kubeClient.AppsV1().StatefulSets(plan.Namespace).List(ctx, metav1.ListOptions{LabelSelector: componentType.StatefulSets})
+		if err != nil {
+			return fmt.Errorf("unable to list the %s statefulsets in namespace %s: %w", componentType.Name, plan.Namespace, err)
+		}
+		for _, sts := range statefulSets.Items {
+			replicas := originalReplicas(sts.ObjectMeta, sts.Spec.Replicas)
+			if definition.fresh && replicas == 0 {
+				continue
+			}
+			plan.StatefulSets = append(plan.StatefulSets, ScaleTarget{Name: sts.Name, Replicas: replicas, Component: componentType.Name})
+		}
+	}
+	for _, job := range databaseRestoreJobs(definition, plan) {
+		plan.Jobs = append(plan.Jobs, job.Name)
+	}
+	return nil
+}
+
+func databaseRestoreJobs(definition RestoreDefinition, plan *Plan) []*batchv1.Job {
+	var jobs []*batchv1.Job
+	for _, database := range definition.databases {
+		if !contains(plan.Components, database.Name) {
+			continue
+		}
+		job := postgres.NewClient(nil, plan.Namespace, database).RestoreJob(plan.BackupID)
+		spec := &job.Spec.Template.Spec
+		for i := range spec.InitContainers {
+			spec.InitContainers[i].Image = mirrorImage(spec.InitContainers[i].Image, definition.jobOverrides.ImageRegistry)
+		}
+		for i := range spec.Containers {
+			spec.Containers[i].Image = mirrorImage(spec.Containers[i].Image, definition.jobOverrides.ImageRegistry)
+		}
+		for _, secret := range definition.jobOverrides.ImagePullSecrets {
+			job.Spec.Template.Spec.ImagePullSecrets = append(job.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs
+}
+
+// restoreDatabases waits until the apps using the databases are gone and restores the dumps.
+func (r *restoreRun) restoreDatabases(ctx context.Context) error {
+	plan := &r.journal.Plan
+	jobs := databaseRestoreJobs(r.definition, plan)
+	if len(jobs) == 0 {
+		return nil
+	}
+	for _, target := range plan.StatefulSets {
+		if _, ok := componentTypeOf(target.Component); !ok {
+			continue
+		}
+		sts, err := r.kubeClient.AppsV1().StatefulSets(plan.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		err = waitForBrokersGone(ctx, r.kubeClient, sts, r.jobRunner().timeout)
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println("restoring databases")
+	return r.jobRunner().Run(ctx, postgres.RestoreJobSelector, jobs)
+}
+
+// restoreResources puts the kept Secrets and ConfigMaps of the restored components back.
+func (r *restoreRun) restoreResources(ctx context.Context) error {
+	plan := &r.journal.Plan
+	for _, name := range plan.Components {
+		selector := r.definition.resources[name]
+		if selector == "" || r.definition.sourceKubeClient == nil {
+			continue
+		}
+		fmt.Println("restoring the resources of", name)
+		client := resources.NewClient(r.definition.sourceKubeClient, r.definition.sourceNamespace, name, selector)
+		err := client.Restore(ctx, plan.BackupID, r.kubeClient, plan.Namespace)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
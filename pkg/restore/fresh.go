@@ -0,0 +1,108 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// preparePVCs creates the Zeebe PVCs missing in a fresh installation from the volumeClaimTemplate, named like
+// the StatefulSet would name them, so the brokers pick them up once they start. It then waits until every PVC
+// is bound or waits for its first consumer, which is the restore Job.
+func (r *restoreRun) preparePVCs(ctx context.Context) error {
+	plan := &r.journal.Plan
+	if plan.Zeebe == nil {
+		return nil
+	}
+	zeebe, _, err := r.zeebeStatefulSet(ctx)
+	if err != nil {
+		return err
+	}
+	return createBrokerPVCs(ctx, r.kubeClient, zeebe, plan.Zeebe, r.jobRunner().timeout)
+}
+
+// createBrokerPVCs creates the PVCs of the topology marked with Create in the namespace of the StatefulSet
+// and waits for them.
+func createBrokerPVCs(ctx context.Context, kubeClient *kubernetes.Clientset, zeebe *apps.StatefulSet, topology *ZeebeTopology, timeout time.Duration) error {
+	template, err := dataClaimTemplate(zeebe)
+	if err != nil {
+		return err
+	}
+
+	pvcs := kubeClient.CoreV1().PersistentVolumeClaims(zeebe.Namespace)
+	for _, broker := range topology.Brokers {
+		if !broker.Create {
+			continue
+		}
+		pvc := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        broker.PVC,
+				Namespace:   zeebe.Namespace,
+				Labels:      map[string]string{},
+				Annotations: template.Annotations,
+			},
+			Spec: *template.Spec.DeepCopy(),
+		}
+		for key, value := range template.Labels {
+			pvc.Labels[key] = value
+		}
+		if zeebe.Spec.Selector != nil {
+			for key, value := range zeebe.Spec.Selector.MatchLabels {
+				pvc.Labels[key] = value
+			}
+		}
+		_, err := pvcs.Create(ctx, pvc, metav1.CreateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsAlreadyExists(err) {
+			fmt.Println("pvc already exists", pvc.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("created pvc", pvc.Name)
+	}
+
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, broker := range topology.Brokers {
+			pvc, err := pvcs.Get(ctx, broker.PVC, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				fmt.Println("waiting for pvc", broker.PVC)
+				return false, nil
+			}
+			if err != nil {
+				return false, err
+			}
+			if pvc.Status.Phase == corev1.ClaimBound {
+				continue
+			}
+			waitsForConsumer, err := waitsForFirstConsumer(ctx, kubeClient, pvc)
+			if err != nil {
+				return false, err
+			}
+			if !waitsForConsumer {
+				fmt.Printf("waiting for pvc %s in phase %s\n", pvc.Name, pvc.Status.Phase)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func waitsForFirstConsumer(ctx context.Context, kubeClient *kubernetes.Clientset, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false, nil
+	}
+	storageClass, err := kubeClient.StorageV1().StorageClasses().Get(ctx, *pvc.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return storageClass.VolumeBindingMode != nil && *storageClass.VolumeBindingMode == storagev1.VolumeBindingWaitForFirstConsumer, nil
+}
@@ -0,0 +1,185 @@
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const defaultMonitoringPort = 9600
+
+// DefaultHealthTimeout is how long a restore waits for each component to become healthy after scaling it up.
+const DefaultHealthTimeout = 10 * time.Minute
+
+// partitionStatus is one entry of the /actuator/partitions response of a broker.
+type partitionStatus struct {
+	Role string `json:"role"`
+}
+
+type actuatorHealth struct {
+	Status string `json:"status"`
+}
+
+// zeebeTopologyHealthy asks every broker pod of the StatefulSet, through the API server proxy, for its health and
+// its partitions. It returns nil once every broker is UP and every partition of the topology has a leader.
+func zeebeTopologyHealthy(ctx context.Context, kubeClient *kubernetes.Clientset, sts *apps.StatefulSet, topology *ZeebeTopology) error {
+	port := strconv.Itoa(monitoringPort(sts))
+	leaders := map[int]string{}
+	for _, broker := range topology.Brokers {
+		pod := fmt.Sprintf("%s-%d", sts.Name, broker.NodeID)
+		pods := kubeClient.CoreV1().Pods(sts.Namespace)
+
+		data, err := pods.ProxyGet("http", pod, port, "actuator/health", nil).DoRaw(ctx)
+		if err != nil {
+			return fmt.Errorf("broker %s is not healthy: %w", pod, err)
+		}
+		var health actuatorHealth
+		err = json.Unmarshal(data, &health)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling health of broker %s: %w", pod, err)
+		}
+		if health.Status != "UP" {
+			return fmt.Errorf("broker %s is %s", pod, health.Status)
+		}
+
+		data, err = pods.ProxyGet("http", pod, port, "actuator/partitions", nil).DoRaw(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to get the partitions of broker %s: %w", pod, err)
+		}
+		var partitions map[string]partitionStatus
+		err = json.Unmarshal(data, &partitions)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling partitions of broker %s: %w", pod, err)
+		}
+		for id, partition := range partitions {
+			partitionID, err := strconv.Atoi(id)
+			if err == nil && partition.Role == "LEADER" {
+				leaders[partitionID] = pod
+			}
+		}
+	}
+
+	var missing []int
+	for partitionID := 1; partitionID <= topology.PartitionsCount; partitionID++ {
+		if leaders[partitionID] == "" {
+			missing = append(missing, partitionID)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Ints(missing)
+		return fmt.Errorf("partitions %v have no leader", missing)
+	}
+	return nil
+}
+
+// monitoringPort is the container port of the broker named http, where the actuator listens.
+func monitoringPort(sts *apps.StatefulSet) int {
+	for _, port := range sts.Spec.Template.Spec.Containers[0].Ports {
+		if port.Name == "http" {
+			return int(port.ContainerPort)
+		}
+	}
+	return defaultMonitoringPort
+}
+
+// webappHealthy returns nil if the /actuator/health of the mgmt endpoint reports UP.
+func webappHealthy(ctx context.Context, mgmtURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s/actuator/health", mgmtURL), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var health actuatorHealth
+	err = json.Unmarshal(respBody, &health)
+	if err != nil {
+		return fmt.Errorf("unexpected health response %s: %s", resp.Status, respBody)
+	}
+	if health.Status != "UP" {
+		return fmt.Errorf("%s is %s", mgmtURL, health.Status)
+	}
+	return nil
+}
+
+// statefulSetReady returns nil once all replicas of the StatefulSet are ready.
+func statefulSetReady(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, name string) error {
+	sts, err := kubeClient.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if sts.Spec.Replicas != nil && sts.Status.ReadyReplicas < *sts.Spec.Replicas {
+		return fmt.Errorf("statefulset %s has %d of %d replicas ready", name, sts.Status.ReadyReplicas, *sts.Spec.Replicas)
+	}
+	return nil
+}
+
+// deploymentAvailable returns nil once the Deployment rolled out all replicas and they are available.
+func deploymentAvailable(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, name string) error {
+	deployment, err := kubeClient.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return fmt.Errorf("deployment %s is not rolled out yet", name)
+	}
+	if deployment.Status.UpdatedReplicas < replicas || deployment.Status.AvailableReplicas < replicas {
+		return fmt.Errorf("deployment %s has %d of %d replicas available", name, deployment.Status.AvailableReplicas, replicas)
+	}
+	return nil
+}
+
+// waitHealthy polls check until it returns nil. On timeout it returns the last error of check.
+func waitHealthy(ctx context.Context, name string, timeout time.Duration, check func(ctx context.Context) error) error {
+	var lastErr error
+	err := wait.PollUntilContextTimeout(ctx, 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		lastErr = check(ctx)
+		if lastErr != nil {
+			fmt.Printf("waiting for %s: %v\n", name, lastErr)
+		}
+		return lastErr == nil, nil
+	})
+	if err != nil && lastErr != nil {
+		return fmt.Errorf("%s not healthy within %s: %w", name, timeout, lastErr)
+	}
+	return err
+}
+
+// waitForDeploymentsStopped waits until no pod of the scaled down Deployments is left, so no webapp writes to
+// Elasticsearch while its indices are deleted and restored.
+func waitForDeploymentsStopped(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan, timeout time.Duration) error {
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, target := range plan.Deployments {
+			deployment, err := kubeClient.AppsV1().Deployments(plan.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			if deployment.Status.Replicas > 0 {
+				fmt.Printf("waiting for %d pods of %s to terminate\n", deployment.Status.Replicas, deployment.Name)
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,236 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/backup-client/zeebe"
+	"c8backup/pkg/manifest"
+)
+
+// zeebePartition is what the integrity check needs to know of a partition of the Zeebe backup.
+type zeebePartition struct {
+	ID                 int
+	State              string
+	CheckpointPosition int
+	CreatedAt          time.Time
+}
+
+// VerifyBackup checks that every part of the backup is present and consistent, without restoring anything: the
+// backups of the components are completed, their snapshots are intact in the repository, every Zeebe partition has
+// a checkpoint, and the records snapshot covers the checkpoints for the way exporting was paused. The parts are taken
+// from the signed manifest, or asked from the components for backups without one.
+func VerifyBackup(definition RestoreDefinition) *VerifyReport {
+	ctx := context.Background()
+	report := &VerifyReport{BackupID: definition.backupID, Namespace: definition.sourceNamespace}
+	elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
+
+	backupManifest, warnings, err := trustedManifest(ctx, definition)
+	report.Warnings = append(report.Warnings, warnings...)
+	if !report.check("manifest signature", err) {
+		return report
+	}
+	entries, err := backupEntries(ctx, definition, backupManifest)
+	if err == nil && len(entries) == 0 {
+		err = fmt.Errorf("no components found for backup %d", definition.backupID)
+	}
+	if !report.check("components of the backup found", err) {
+		return report
+	}
+
+	var records *elastic.SnapshotResponse
+	for _, entry := range entries {
+		err := entryCompleted(entry)
+		if !report.check(entry.Name+" backup completed", err) || len(entry.Snapshots) == 0 {
+			continue
+		}
+		snapshots, err := elasticClient.GetSnapshots(ctx, entry.Snapshots)
+		if err == nil {
+			err = snapshotsIntact(entry.Snapshots, snapshots)
+		}
+		if report.check(entry.Name+" snapshots intact", err) && entry.Name == componentRecords {
+			records = snapshots
+		}
+	}
+
+	partitions, err := zeebePartitions(ctx, definition, backupManifest)
+	switch {
+	case err != nil:
+		report.check("zeebe partitions found", err)
+	case partitions == nil:
+		report.Warnings = append(report.Warnings, "no zeebe backup found, pass --zeebe to check its partitions")
+	default:
+		report.check(fmt.Sprintf("%d zeebe partitions completed with a checkpoint", len(partitions)), partitionsCompleted(partitions))
+		if records != nil {
+			report.check("records snapshot covers the zeebe checkpoints", recordsCoverCheckpoints(records, partitions, pauseOf(backupManifest)))
+		} else {
+			report.Warnings = append(report.Warnings, "no zeebe records snapshot, its coverage of the checkpoints is not checked")
+		}
+	}
+
+	if definition.deepVerify {
+		fmt.Println("verifying the integrity of repository", definition.backupRepositoryName)
+		err := elasticClient.VerifyIntegrity(ctx)
+		if errors.Is(err, elastic.ErrIntegrityUnsupported) {
+			report.Warnings = append(report.Warnings, err.Error())
+		} else {
+			report.check("repository "+definition.backupRepositoryName+" integrity", err)
+		}
+	}
+	return report
+}
+
+// backupEntries returns the components of the manifest, or asks the configured components for their backup.
+func backupEntries(ctx context.Context, definition RestoreDefinition, backupManifest *manifest.Manifest) ([]manifest.Component, error) {
+	if backupManifest != nil {
+		return backupManifest.Components, nil
+	}
+	var entries []manifest.Component
+	for _, client := range backupClients(definition, configuredComponents(definition)) {
+		status, err := client.Status(ctx, definition.backupID)
+		if err != nil {
+			return nil, fmt.Errorf("unable to get backup %d of %s: %w", definition.backupID, client.Name(), err)
+		}
+		entries = append(entries, manifest.Component{
+			Name:      client.Name(),
+			State:     status.State,
+			Reason:    status.Reason,
+			Snapshots: status.Snapshots,
+		})
+	}
+	return entries, nil
+}
+
+func entryCompleted(entry manifest.Component) error {
+	if entry.State == component.StateCompleted {
+		return nil
+	}
+	if entry.Reason != "" {
+		return fmt.Errorf("%s: %s", entry.State, entry.Reason)
+	}
+	return fmt.Errorf("%s, not %s", entry.State, component.StateCompleted)
+}
+
+// snapshotsIntact checks that each named snapshot is in the repository, succeeded and has no failed shards.
+func snapshotsIntact(names []string, snapshots *elastic.SnapshotResponse) error {
+	var errorList []error
+	for _, name := range names {
+		found := false
+		for _, snapshot := range snapshots.Snapshots {
+			if snapshot.Snapshot != name {
+				continue
+			}
+			found = true
+			if snapshot.State != "SUCCESS" {
+				errorList = append(errorList, fmt.Errorf("snapshot %s is %s", name, snapshot.State))
+			}
+			if snapshot.Shards.Failed != 0 {
+				errorList = append(errorList, fmt.Errorf("snapshot %s has %d of %d shards failed", name, snapshot.Shards.Failed, snapshot.Shards.Total))
+			}
+		}
+		if !found {
+			errorList = append(errorList, fmt.Errorf("snapshot %s is not in the repository", name))
+		}
+	}
+	return errors.Join(errorList...)
+}
+
+// zeebePartitions returns the partitions of the Zeebe backup from the mgmt endpoint, which tells their states, or
+// else from the manifest. It returns nil if neither knows the backup.
+func zeebePartitions(ctx context.Context, definition RestoreDefinition, backupManifest *manifest.Manifest) ([]zeebePartition, error) {
+	var partitions []zeebePartition
+	if definition.zeebeURL != "" {
+		backup, err := zeebeBackup.NewZeebeClient(definition.zeebeURL).GetBackup(ctx, definition.backupID)
+		if err != nil {
+			return nil, err
+		}
+		if backup == nil {
+			return nil, fmt.Errorf("zeebe backup %d not found", definition.backupID)
+		}
+		for _, detail := range backup.Details {
+			partitions = append(partitions, zeebePartition{
+				ID:                 detail.PartitionId,
+				State:              detail.State,
+				CheckpointPosition: detail.CheckpointPosition,
+				CreatedAt:          detail.CreatedAt,
+			})
+		}
+		return partitions, nil
+	}
+	if backupManifest == nil || backupManifest.Zeebe == nil {
+		return nil, nil
+	}
+	// The manifest has the state of the whole Zeebe backup, not of each partition
+	state := string(component.StateNotFound)
+	if entry := backupManifest.Component(ComponentZeebe); entry != nil {
+		state = string(entry.State)
+	}
+	for _, partition := range backupManifest.Zeebe.Partitions {
+		partitions = append(partitions, zeebePartition{
+			ID:                 partition.ID,
+			State:              state,
+			CheckpointPosition: partition.CheckpointPosition,
+			CreatedAt:          partition.CreatedAt,
+		})
+	}
+	return partitions, nil
+}
+
+func partitionsCompleted(partitions []zeebePartition) error {
+	if len(partitions) == 0 {
+		return fmt.Errorf("the zeebe backup has no partitions")
+	}
+	var errorList []error
+	for _, partition := range partitions {
+		if partition.State != "COMPLETED" {
+			errorList = append(errorList, fmt.Errorf("partition %d is %s", partition.ID, partition.State))
+		}
+		if partition.CheckpointPosition <= 0 {
+			errorList = append(errorList, fmt.Errorf("partition %d has no checkpoint position", partition.ID))
+		}
+	}
+	return errors.Join(errorList...)
+}
+
+// pauseOf returns how exporting was paused for the backup, nil if the manifest doesn't tell.
+func pauseOf(backupManifest *manifest.Manifest) *manifest.Zeebe {
+	if backupManifest == nil || backupManifest.Zeebe == nil || backupManifest.Zeebe.PauseMode == "" {
+		return nil
+	}
+	return backupManifest.Zeebe
+}
+
+// recordsCoverCheckpoints checks that the records snapshot holds every record exported up to the checkpoints, Zeebe
+// exports the rest again after the restore. After a hard pause nothing is exported until the checkpoints, so the
+// snapshot has to be started after the checkpoint of every partition. After a soft pause the exporters go on without
+// moving their positions and the snapshot is taken right after the pause, so it has to be started after the pause.
+// Backups without the pause in their manifest were paused hard.
+func recordsCoverCheckpoints(records *elastic.SnapshotResponse, partitions []zeebePartition, pause *manifest.Zeebe) error {
+	if len(records.Snapshots) == 0 {
+		return fmt.Errorf("the records snapshot is not in the repository")
+	}
+	started := records.Snapshots[0].StartTime
+	if pause != nil && pause.PauseMode != string(zeebeBackup.PauseHard) {
+		if started.Before(pause.PausedAt) {
+			return fmt.Errorf("the records snapshot started at %s, before exporting was paused (%s) at %s",
+				started.Format(time.RFC3339), pause.PauseMode, pause.PausedAt.Format(time.RFC3339))
+		}
+		return nil
+	}
+	var errorList []error
+	for _, partition := range partitions {
+		if partition.CreatedAt.IsZero() {
+			errorList = append(errorList, fmt.Errorf("the checkpoint time of partition %d is unknown", partition.ID))
+			continue
+		}
+		if started.Before(partition.CreatedAt) {
+			errorList = append(errorList, fmt.Errorf("the records snapshot started at %s, before the checkpoint of partition %d at %s",
+				started.Format(time.RFC3339), partition.ID, partition.CreatedAt.Format(time.RFC3339)))
+		}
+	}
+	return errors.Join(errorList...)
+}
@@ -0,0 +1,144 @@
+package restore
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/manifest"
+)
+
+// snapshotResponse builds the response of the snapshot API from JSON, its snapshots are anonymous structs.
+func snapshotResponse(t *testing.T, data string) *elastic.SnapshotResponse {
+	var response elastic.SnapshotResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		t.Fatalf("invalid snapshot response: %v", err)
+	}
+	return &response
+}
+
+func TestSnapshotsIntact(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		response string
+		wantErr  bool
+	}{
+		{
+			name:     "all succeeded",
+			names:    []string{"a", "b"},
+			response: `{"snapshots":[{"snapshot":"a","state":"SUCCESS","shards":{"total":3}},{"snapshot":"b","state":"SUCCESS"}]}`,
+		},
+		{
+			name:     "missing",
+			names:    []string{"a", "b"},
+			response: `{"snapshots":[{"snapshot":"a","state":"SUCCESS"}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "partial",
+			names:    []string{"a"},
+			response: `{"snapshots":[{"snapshot":"a","state":"PARTIAL"}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "failed shards",
+			names:    []string{"a"},
+			response: `{"snapshots":[{"snapshot":"a","state":"SUCCESS","shards":{"total":3,"failed":1}}]}`,
+			wantErr:  true,
+		},
+		{
+			name:     "nothing in the repository",
+			names:    []string{"a"},
+			response: `{"snapshots":[]}`,
+			wantErr:  true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := snapshotsIntact(test.names, snapshotResponse(t, test.response))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("snapshotsIntact() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestPartitionsCompleted(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name       string
+		partitions []zeebePartition
+		wantErr    bool
+	}{
+		{
+			name: "all completed",
+			partitions: []zeebePartition{
+				{ID: 1, State: "COMPLETED", CheckpointPosition: 10, CreatedAt: now},
+				{ID: 2, State: "COMPLETED", CheckpointPosition: 12, CreatedAt: now},
+			},
+		},
+		{name: "no partitions", wantErr: true},
+		{
+			name: "one in progress",
+			partitions: []zeebePartition{
+				{ID: 1, State: "COMPLETED", CheckpointPosition: 10},
+				{ID: 2, State: "IN_PROGRESS", CheckpointPosition: 12},
+			},
+			wantErr: true,
+		},
+		{
+			name:       "no checkpoint",
+			partitions: []zeebePartition{{ID: 1, State: "COMPLETED"}},
+			wantErr:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := partitionsCompleted(test.partitions)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("partitionsCompleted() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordsCoverCheckpoints(t *testing.T) {
+	checkpoint := time.Date(2023, 5, 4, 10, 0, 0, 0, time.UTC)
+	partitions := []zeebePartition{
+		{ID: 1, CreatedAt: checkpoint},
+		{ID: 2, CreatedAt: checkpoint.Add(10 * time.Second)},
+	}
+	pausedAt := checkpoint.Add(-time.Minute)
+	tests := []struct {
+		name       string
+		started    string
+		partitions []zeebePartition
+		pause      *manifest.Zeebe
+		wantErr    bool
+	}{
+		{name: "hard, after every checkpoint", started: "2023-05-04T10:00:30Z", partitions: partitions, pause: &manifest.Zeebe{PauseMode: "hard", PausedAt: pausedAt}},
+		{name: "hard, before a checkpoint", started: "2023-05-04T10:00:05Z", partitions: partitions, pause: &manifest.Zeebe{PauseMode: "hard", PausedAt: pausedAt}, wantErr: true},
+		{name: "unknown pause, taken as hard", started: "2023-05-04T10:00:05Z", partitions: partitions, wantErr: true},
+		{name: "unknown pause, after every checkpoint", started: "2023-05-04T10:00:30Z", partitions: partitions},
+		{name: "hard, checkpoint time unknown", started: "2023-05-04T10:00:30Z", partitions: []zeebePartition{{ID: 1}}, wantErr: true},
+		{name: "soft, after the pause and before the checkpoints", started: "2023-05-04T09:59:30Z", partitions: partitions, pause: &manifest.Zeebe{PauseMode: "soft", PausedAt: pausedAt}},
+		{name: "soft, before the pause", started: "2023-05-04T09:58:30Z", partitions: partitions, pause: &manifest.Zeebe{PauseMode: "soft", PausedAt: pausedAt}, wantErr: true},
+		{name: "soft, checkpoint time unknown", started: "2023-05-04T09:59:30Z", partitions: []zeebePartition{{ID: 1}}, pause: &manifest.Zeebe{PauseMode: "soft", PausedAt: pausedAt}},
+		{name: "unknown mode, after the pause", started: "2023-05-04T09:59:30Z", partitions: partitions, pause: &manifest.Zeebe{PauseMode: "unknown", PausedAt: pausedAt}},
+		{name: "no records snapshot", partitions: partitions, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := `{"snapshots":[]}`
+			if test.started != "" {
+				response = `{"snapshots":[{"snapshot":"camunda_zeebe_records_1","state":"SUCCESS","start_time":"` + test.started + `"}]}`
+			}
+			err := recordsCoverCheckpoints(snapshotResponse(t, response), test.partitions, test.pause)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("recordsCoverCheckpoints() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const DefaultJobTimeout = 30 * time.Minute
+
+// podLogTailLines is how much of a failed Job's pod log is printed.
+const podLogTailLines = int64(100)
+
+// JobRunner creates Jobs and watches them until all of them succeeded, one failed or the timeout is reached.
+// The Jobs and their pods are deleted afterwards in any case.
+type JobRunner struct {
+	kubeClient kubernetes.Interface
+	namespace  string
+	timeout    time.Duration
+}
+
+type jobResult struct {
+	name string
+	err  error
+}
+
+func NewJobRunner(kubeClient kubernetes.Interface, namespace string, timeout time.Duration) *JobRunner {
+	if timeout == 0 {
+		timeout = DefaultJobTimeout
+	}
+	return &JobRunner{
+		kubeClient: kubeClient,
+		namespace:  namespace,
+		timeout:    timeout,
+	}
+}
+
+// Run creates the jobs, all of which have to carry the labelSelector, and waits for them.
+func (j *JobRunner) Run(ctx context.Context, labelSelector string, jobs []*batchv1.Job) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, j.timeout)
+	defer cancel()
+	defer j.cleanup(jobs)
+
+	names := map[string]bool{}
+	pending := map[string]bool{}
+	for _, job := range jobs {
+		names[job.Name] = true
+		pending[job.Name] = true
+	}
+
+	results := make(chan jobResult, len(jobs))
+	reported := map[string]bool{}
+	handle := func(obj interface{}) {
+		job, ok := obj.(*batchv1.Job)
+		if !ok || !names[job.Name] || reported[job.Name] {
+			return
+		}
+		for _, condition := range job.Status.Conditions {
+			if condition.Status != corev1.ConditionTrue {
+				continue
+			}
+			switch condition.Type {
+			case batchv1.JobComplete:
+				reported[job.Name] = true
+				results <- jobResult{name: job.Name}
+			case batchv1.JobFailed:
+				// Reason is BackoffLimitExceeded or DeadlineExceeded
+				reported[job.Name] = true
+				results <- jobResult{name: job.Name, err: fmt.Errorf("job %s failed: %s %s", job.Name, condition.Reason, condition.Message)}
+			}
+		}
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(j.kubeClient, 0,
+		informers.WithNamespace(j.namespace),
+		informers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			options.LabelSelector = labelSelector
+		}))
+	informer := factory.Batch().V1().Jobs().Informer()
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: handle,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			handle(newObj)
+		},
+	})
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+
+	for _, job := range jobs {
+		create, err := j.kubeClient.BatchV1().Jobs(j.namespace).Create(ctx, job, metav1.CreateOptions{FieldManager: "c8-backup"})
+		if apierrors.IsAlreadyExists(err) {
+			// Left by an interrupted run, maybe of another backup: its outcome says nothing about this run
+			fmt.Println("job already exists, recreating it", job.Name)
+			create, err = j.recreate(ctx, job)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println("Created job", create.Name)
+	}
+
+	// The handlers run on a single goroutine, so reported needs no lock
+	factory.Start(stop)
+
+	for len(pending) > 0 {
+		select {
+		case result := <-results:
+			delete(pending, result.name)
+			if result.err != nil {
+				j.printLogs(result.name)
+				return result.err
+			}
+			fmt.Println("job succeeded", result.name)
+		case <-ctx.Done():
+			for name := range pending {
+				j.printLogs(name)
+			}
+			return fmt.Errorf("jobs %v did not finish within %s: %w", keys(pending), j.timeout, ctx.Err())
+		}
+	}
+	return nil
+}
+
+// recreate deletes the existing Job of the same name with its pods, waits until it is gone and creates the job.
+func (j *JobRunner) recreate(ctx context.Context, job *batchv1.Job) (*batchv1.Job, error) {
+	propagation := metav1.DeletePropagationForeground
+	err := j.kubeClient.BatchV1().Jobs(j.namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, j.timeout, true, func(ctx context.Context) (bool, error) {
+		_, err := j.kubeClient.BatchV1().Jobs(j.namespace).Get(ctx, job.Name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to delete the existing job %s: %w", job.Name, err)
+	}
+	return j.kubeClient.BatchV1().Jobs(j.namespace).Create(ctx, job, metav1.CreateOptions{FieldManager: "c8-backup"})
+}
+
+// printLogs streams the tail of the logs of the pods of a job to stdout.
+func (j *JobRunner) printLogs(jobName string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	pods, err := j.kubeClient.CoreV1().Pods(j.namespace).List(ctx, metav1.ListOptions{LabelSelector: "job-name=" + jobName})
+	if err != nil {
+		fmt.Println("unable to list pods of job", jobName, err)
+		return
+	}
+	tailLines := podLogTailLines
+	for _, pod := range pods.Items {
+		fmt.Printf("----- logs of pod %s (%s) -----\n", pod.Name, pod.Status.Phase)
+		stream, err := j.kubeClient.CoreV1().Pods(j.namespace).GetLogs(pod.Name, &corev1.PodLogOptions{TailLines: &tailLines}).Stream(ctx)
+		if err != nil {
+			fmt.Println("unable to get logs", err)
+			continue
+		}
+		_, _ = io.Copy(os.Stdout, stream)
+		stream.Close()
+	}
+}
+
+// cleanup deletes the jobs in the background propagation policy, so that their pods are removed as well.
+func (j *JobRunner) cleanup(jobs []*batchv1.Job) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	propagation := metav1.DeletePropagationBackground
+	for _, job := range jobs {
+		err := j.kubeClient.BatchV1().Jobs(j.namespace).Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !apierrors.IsNotFound(err) {
+			fmt.Println("unable to delete job", job.Name, err)
+		}
+	}
+}
+
+func keys(m map[string]bool) []string {
+	var result []string
+	for key := range m {
+		result = append(result, key)
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const journalConfigMapName = "c8backup-restore-journal"
+const journalKey = "journal.json"
+
+type Step string
+
+const (
+	StepShutdownApps     Step = "shutdown-apps"
+	StepPreparePVCs      Step = "prepare-pvcs"
+	StepDeleteIndices    Step = "delete-indices"
+	StepDeleteZeebeData  Step = "delete-zeebe-data"
+	StepRestoreSnapshots Step = "restore-snapshots"
+	StepRestoreZeebe     Step = "restore-zeebe"
+	StepRestoreDatabases Step = "restore-databases"
+	StepRestoreResources Step = "restore-resources"
+	StepResetApps        Step = "reset-apps"
+)
+
+// Journal records the plan of a running restore, including the original replica counts, and the steps that
+// already completed. It lives in a ConfigMap so an interrupted restore can be resumed or aborted from anywhere.
+type Journal struct {
+	Plan              Plan      `json:"plan"`
+	StartedAt         time.Time `json:"startedAt"`
+	CompletedSteps    []Step    `json:"completedSteps"`
+	RestoredSnapshots []string  `json:"restoredSnapshots"`
+
+	configMap *corev1.ConfigMap
+}
+
+func newJournal(plan *Plan) *Journal {
+	return &Journal{
+		Plan:      *plan,
+		StartedAt: time.Now(),
+	}
+}
+
+// loadJournal returns the journal of an unfinished restore in the namespace, or nil if there is none.
+func loadJournal(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string) (*Journal, error) {
+	configMap, err := kubeClient.CoreV1().ConfigMaps(namespace).Get(ctx, journalConfigMapName, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var journal Journal
+	err = json.Unmarshal([]byte(configMap.Data[journalKey]), &journal)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling restore journal %s/%s: %w", namespace, journalConfigMapName, err)
+	}
+	journal.configMap = configMap
+	return &journal, nil
+}
+
+func (j *Journal) save(ctx context.Context, kubeClient *kubernetes.Clientset) error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// The ConfigMap is only replaced on success, client-go returns an empty one on errors
+	var configMap *corev1.ConfigMap
+	configMaps := kubeClient.CoreV1().ConfigMaps(j.Plan.Namespace)
+	if j.configMap == nil {
+		configMap, err = configMaps.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      journalConfigMapName,
+				Namespace: j.Plan.Namespace,
+				Labels: map[string]string{
+					"app.kubernetes.io/managed-by": "c8backup",
+				},
+			},
+			Data: map[string]string{journalKey: string(data)},
+		}, metav1.CreateOptions{FieldManager: "c8-backup"})
+	} else {
+		update := j.configMap.DeepCopy()
+		update.Data = map[string]string{journalKey: string(data)}
+		configMap, err = configMaps.Update(ctx, update, metav1.UpdateOptions{FieldManager: "c8-backup"})
+	}
+	if err != nil {
+		return err
+	}
+	j.configMap = configMap
+	return nil
+}
+
+func (j *Journal) delete(ctx context.Context, kubeClient *kubernetes.Clientset) error {
+	err := kubeClient.CoreV1().ConfigMaps(j.Plan.Namespace).Delete(ctx, journalConfigMapName, metav1.DeleteOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (j *Journal) completed(step Step) bool {
+	for _, completedStep := range j.CompletedSteps {
+		if completedStep == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *Journal) snapshotRestored(name string) bool {
+	for _, snapshot := range j.RestoredSnapshots {
+		if snapshot == name {
+			return true
+		}
+	}
+	return false
+}
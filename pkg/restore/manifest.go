@@ -0,0 +1,98 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/manifest"
+)
+
+// componentRecords is the Elasticsearch snapshot of the Zeebe records, restored along with Zeebe
+const componentRecords = "elasticsearch"
+
+// trustedManifest returns the signed manifest of the backup, or nil if the components have to be asked instead: for
+// backups without a manifest or with an unsigned one, and with --ignore-manifest. A manifest that doesn't match its
+// signature fails the restore.
+func trustedManifest(ctx context.Context, definition RestoreDefinition) (*manifest.Manifest, []string, error) {
+	if definition.ignoreManifest || definition.sourceKubeClient == nil {
+		return nil, nil, nil
+	}
+	backupManifest, err := manifest.Read(ctx, definition.sourceKubeClient, definition.sourceNamespace, definition.backupID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if backupManifest == nil {
+		return nil, []string{fmt.Sprintf("backup %d has no manifest, asking the components", definition.backupID)}, nil
+	}
+	err = manifest.Verify(ctx, definition.sourceKubeClient, definition.sourceNamespace, backupManifest)
+	if errors.Is(err, manifest.ErrUnsigned) {
+		return nil, []string{fmt.Sprintf("%v, asking the components", err)}, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w, pass --ignore-manifest to ask the components instead", err)
+	}
+	return backupManifest, nil, nil
+}
+
+// manifestEntries are the names the components are recorded under in the manifest.
+func manifestEntries(components []string) []string {
+	var entries []string
+	for _, name := range components {
+		entries = append(entries, name)
+		if name == ComponentZeebe {
+			entries = append(entries, componentRecords)
+		}
+	}
+	return entries
+}
+
+// manifestSnapshots returns the snapshots of the components as recorded in the manifest. Every component has to be
+// completed in the backup, except the records snapshot, which is only taken with --elastic. Entries the backup
+// stopped waiting for before they were done are refreshed from the component.
+func manifestSnapshots(ctx context.Context, definition RestoreDefinition, backupManifest *manifest.Manifest, components []string) ([]string, error) {
+	var snapshots []string
+	for _, name := range manifestEntries(components) {
+		entry := backupManifest.Component(name)
+		if entry == nil && name == componentRecords {
+			continue
+		}
+		if entry == nil {
+			return nil, fmt.Errorf("%s is not part of backup %d", name, backupManifest.BackupID)
+		}
+		if !entry.State.Done() {
+			err := refreshEntry(ctx, definition, components, entry)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if entry.State != component.StateCompleted {
+			return nil, fmt.Errorf("backup %d of %s is %s, not %s", backupManifest.BackupID, name, entry.State, component.StateCompleted)
+		}
+		snapshots = append(snapshots, entry.Snapshots...)
+	}
+	return snapshots, nil
+}
+
+// refreshEntry updates an entry that was still in progress when the manifest was written with the current state of
+// the backup. The entry is changed in place, the plan keeps the refreshed manifest.
+func refreshEntry(ctx context.Context, definition RestoreDefinition, components []string, entry *manifest.Component) error {
+	for _, client := range backupClients(definition, components) {
+		if client.Name() != entry.Name {
+			continue
+		}
+		status, err := client.Status(ctx, definition.backupID)
+		if err != nil {
+			return fmt.Errorf("unable to get backup %d of %s: %w", definition.backupID, entry.Name, err)
+		}
+		fmt.Printf("backup %d of %s was %s when the manifest was written, it is %s now\n", definition.backupID, entry.Name, entry.State, status.State)
+		entry.State = status.State
+		entry.Reason = status.Reason
+		entry.Snapshots = status.Snapshots
+		entry.Indices = nil
+		return nil
+	}
+	return fmt.Errorf("backup %d of %s was %s when the manifest was written, pass its endpoint to check it again",
+		definition.backupID, entry.Name, entry.State)
+}
@@ -0,0 +1,320 @@
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/backup-client/zeebe"
+	"c8backup/pkg/manifest"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Plan is everything a restore is going to touch. It is resolved up front so that it can be reviewed
+// with --dry-run, saved with --plan-file and run later with --apply-plan.
+type Plan struct {
+	Namespace    string             `json:"namespace"`
+	BackupID     int64              `json:"backupId"`
+	Fresh        bool               `json:"fresh,omitempty"`
+	Components   []string           `json:"components"`
+	Warnings     []string           `json:"warnings,omitempty"`
+	Snapshots    []string           `json:"snapshots"`
+	Deployments  []ScaleTarget      `json:"deployments"`
+	StatefulSets []ScaleTarget      `json:"statefulSets"`
+	HPAs         []PausedHPA        `json:"hpas,omitempty"`
+	GitOps       []SuspendedGitOps  `json:"gitOps,omitempty"`
+	Indices      []string           `json:"indices"`
+	Versions     map[string]string  `json:"versions,omitempty"`
+	Manifest     *manifest.Manifest `json:"manifest,omitempty"`
+	Zeebe        *ZeebeTopology     `json:"zeebe,omitempty"`
+	PVCs         []string           `json:"pvcs"`
+	Jobs         []string           `json:"jobs"`
+}
+
+// ScaleTarget is a workload that is scaled to zero during the restore and back to Replicas afterwards. Component
+// is the Camunda component a Deployment runs, if known.
+type ScaleTarget struct {
+	Name      string `json:"name"`
+	Replicas  int32  `json:"replicas"`
+	Component string `json:"component,omitempty"`
+}
+
+func NewPlan(ctx context.Context, kubeClient *kubernetes.Clientset, elasticClient *elastic.Client, definition RestoreDefinition) (*Plan, error) {
+	namespace := definition.namespace
+	backupID := definition.backupID
+	components := configuredComponents(definition)
+	for _, component := range components {
+		err := checkRequirements(definition, component)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if definition.wipeAll && definition.elasticURL == "" {
+		return nil, fmt.Errorf("--wipe-all needs --elastic")
+	}
+	plan := &Plan{
+		Namespace:  namespace,
+		BackupID:   backupID,
+		Fresh:      definition.fresh,
+		Components: components,
+		Warnings:   consistencyWarnings(components),
+	}
+
+	restoreZeebe := contains(components, ComponentZeebe)
+
+	// The signed manifest is the source of truth, only backups without one are looked up in the components
+	backupManifest, warnings, err := trustedManifest(ctx, definition)
+	if err != nil {
+		return nil, err
+	}
+	plan.Manifest = backupManifest
+	plan.Warnings = append(plan.Warnings, warnings...)
+	if plan.Manifest != nil {
+		plan.Snapshots, err = manifestSnapshots(ctx, definition, plan.Manifest, components)
+	} else {
+		plan.Snapshots, err = gatherSnapshotNames(ctx, backupID, backupClients(definition, components))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	deployments, warnings, err := listApps(ctx, kubeClient, namespace, definition.appSelector)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the apps in namespace %s: %w", namespace, err)
+	}
+	plan.Warnings = append(plan.Warnings, warnings...)
+	for _, deployment := range deployments {
+		if !allComponents(components) && !dependsOn(deployment, components) {
+			continue
+		}
+		replicas := originalReplicas(deployment.ObjectMeta, deployment.Spec.Replicas)
+		if definition.fresh && replicas == 0 {
+			// Nothing running, nothing to scale down
+			continue
+		}
+		plan.Deployments = append(plan.Deployments, ScaleTarget{Name: deployment.Name, Replicas: replicas, Component: componentOf(deployment)})
+	}
+	if restoreZeebe {
+		sts, inTarget, err := zeebeStatefulSet(ctx, kubeClient, definition, namespace)
+		if err != nil {
+			return nil, err
+		}
+		replicas := originalReplicas(sts.ObjectMeta, sts.Spec.Replicas)
+		if inTarget && !(definition.fresh && replicas == 0) {
+			plan.StatefulSets = append(plan.StatefulSets, ScaleTarget{Name: sts.Name, Replicas: replicas})
+		}
+
+		plan.Zeebe, err = resolveZeebeTopology(ctx, kubeClient, sts, definition.fresh)
+		if err != nil {
+			return nil, err
+		}
+		err = checkZeebeBackup(ctx, definition, plan)
+		if err != nil {
+			return nil, err
+		}
+		for _, broker := range plan.Zeebe.Brokers {
+			if broker.Create {
+				plan.Jobs = append(plan.Jobs, restoreJobName(broker.PVC))
+				continue
+			}
+			plan.PVCs = append(plan.PVCs, broker.PVC)
+			plan.Jobs = append(plan.Jobs, deletionJobName(broker.PVC), restoreJobName(broker.PVC))
+		}
+	}
+
+	err = checkVersions(ctx, kubeClient, definition, plan)
+	if err != nil {
+		return nil, err
+	}
+	err = planComponents(ctx, kubeClient, definition, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.HPAs, err = findHPAs(ctx, kubeClient, plan)
+	if err != nil {
+		return nil, fmt.Errorf("unable to list the hpas in namespace %s: %w", namespace, err)
+	}
+	plan.GitOps, err = findGitOps(ctx, kubeClient, plan, definition.argoCDNamespace)
+	if err != nil {
+		return nil, err
+	}
+
+	if definition.elasticURL != "" {
+		plan.Indices, err = indicesToDelete(ctx, elasticClient, definition, plan)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// checkZeebeBackup compares the partitions and cluster size of the Zeebe backup with the topology. They are taken from
+// the manifest, or from the Zeebe mgmt endpoint if known, which doesn't tell the cluster size.
+func checkZeebeBackup(ctx context.Context, definition RestoreDefinition, plan *Plan) error {
+	topology := plan.Zeebe
+	if plan.Manifest != nil && plan.Manifest.Zeebe != nil {
+		return checkBackupTopology(topology, len(plan.Manifest.Zeebe.Partitions), plan.Manifest.Zeebe.ClusterSize)
+	}
+	if definition.zeebeURL == "" {
+		fmt.Println("no zeebe url given, unable to check the backup against the zeebe topology")
+		return nil
+	}
+	backup, err := zeebeBackup.NewZeebeClient(definition.zeebeURL).GetBackup(ctx, definition.backupID)
+	if err != nil {
+		return err
+	}
+	if backup == nil {
+		return fmt.Errorf("zeebe backup %d not found", definition.backupID)
+	}
+	return checkBackupTopology(topology, len(backup.Details), 0)
+}
+
+// indicesToDelete returns the indices the snapshots of the plan recreate, plus the existing indices with the
+// prefix of a restored component. Only --wipe-all deletes every index in the cluster.
+func indicesToDelete(ctx context.Context, elasticClient *elastic.Client, definition RestoreDefinition, plan *Plan) ([]string, error) {
+	existing, err := elasticClient.ListIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if definition.wipeAll {
+		return existing, nil
+	}
+
+	// The manifest records the indices of the snapshots, the others are looked up in the repository
+	var indices []string
+	unknown := plan.Snapshots
+	if plan.Manifest != nil {
+		unknown = nil
+		for _, name := range manifestEntries(plan.Components) {
+			entry := plan.Manifest.Component(name)
+			switch {
+			case entry == nil:
+			case len(entry.Indices) > 0:
+				indices = append(indices, entry.Indices...)
+			default:
+				unknown = append(unknown, entry.Snapshots...)
+			}
+		}
+	}
+	if len(unknown) > 0 {
+		snapshots, err := elasticClient.GetSnapshots(ctx, unknown)
+		if err != nil {
+			return nil, err
+		}
+		for _, snapshot := range snapshots.Snapshots {
+			indices = append(indices, snapshot.Indices...)
+		}
+	}
+
+	prefixes := indexPrefixes(definition.indexPrefixes)
+	for _, index := range existing {
+		for _, component := range plan.Components {
+			prefix := prefixes[component]
+			if prefix != "" && strings.HasPrefix(index, prefix) {
+				indices = append(indices, index)
+				break
+			}
+		}
+	}
+
+	sort.Strings(indices)
+	var unique []string
+	for _, index := range indices {
+		if len(unique) == 0 || unique[len(unique)-1] != index {
+			unique = append(unique, index)
+		}
+	}
+	return unique, nil
+}
+
+func ReadPlan(path string) (*Plan, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var plan Plan
+	err = json.Unmarshal(data, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling plan %s: %w", path, err)
+	}
+	return &plan, nil
+}
+
+func (p Plan) Write(path string) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+func (p Plan) Print(w io.Writer) {
+	fmt.Fprintf(w, "Restore plan for backup %d in namespace %s\n", p.BackupID, p.Namespace)
+	fmt.Fprintf(w, "Components: %v\n", p.Components)
+	if p.Manifest != nil {
+		fmt.Fprintf(w, "Signed manifest of %s, taken by c8backup %s in %s\n",
+			p.Manifest.CreatedAt.Format(time.RFC3339), p.Manifest.ToolVersion, p.Manifest.Duration)
+	}
+	if len(p.Versions) > 0 {
+		fmt.Fprintf(w, "Versions of the backup: %v\n", p.Versions)
+	}
+	for _, warning := range p.Warnings {
+		fmt.Fprintf(w, "WARNING: %s\n", warning)
+	}
+	fmt.Fprintln(w, "Snapshots to restore:")
+	for _, snapshot := range p.Snapshots {
+		fmt.Fprintf(w, "  %s\n", snapshot)
+	}
+	fmt.Fprintln(w, "Deployments to scale to 0 (current replicas):")
+	for _, deployment := range p.Deployments {
+		fmt.Fprintf(w, "  %s (%d)\n", deployment.Name, deployment.Replicas)
+	}
+	fmt.Fprintln(w, "StatefulSets to scale to 0 (current replicas):")
+	for _, sts := range p.StatefulSets {
+		fmt.Fprintf(w, "  %s (%d)\n", sts.Name, sts.Replicas)
+	}
+	fmt.Fprintln(w, "HPAs to pause (min, max replicas):")
+	for _, hpa := range p.HPAs {
+		minReplicas := int32(1)
+		if hpa.MinReplicas != nil {
+			minReplicas = *hpa.MinReplicas
+		}
+		fmt.Fprintf(w, "  %s for %s (%d, %d)\n", hpa.Name, hpa.Target, minReplicas, hpa.MaxReplicas)
+	}
+	fmt.Fprintln(w, "GitOps reconciliation to suspend:")
+	for _, gitOps := range p.GitOps {
+		fmt.Fprintf(w, "  %s %s/%s\n", gitOps.Kind, gitOps.Namespace, gitOps.Name)
+	}
+	fmt.Fprintln(w, "Indices to delete:")
+	for _, index := range p.Indices {
+		fmt.Fprintf(w, "  %s\n", index)
+	}
+	if p.Zeebe != nil {
+		fmt.Fprintf(w, "Zeebe statefulset %s: cluster size %d, %d partitions, replication factor %d\n",
+			p.Zeebe.StatefulSet, p.Zeebe.ClusterSize, p.Zeebe.PartitionsCount, p.Zeebe.ReplicationFactor)
+	}
+	if p.Zeebe != nil {
+		fmt.Fprintln(w, "PVCs to create:")
+		for _, broker := range p.Zeebe.Brokers {
+			if broker.Create {
+				fmt.Fprintf(w, "  %s\n", broker.PVC)
+			}
+		}
+	}
+	fmt.Fprintln(w, "PVCs to wipe:")
+	for _, pvc := range p.PVCs {
+		fmt.Fprintf(w, "  %s\n", pvc)
+	}
+	fmt.Fprintln(w, "Jobs to create:")
+	for _, job := range p.Jobs {
+		fmt.Fprintf(w, "  %s\n", job)
+	}
+}
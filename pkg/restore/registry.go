@@ -0,0 +1,100 @@
+package restore
+
+import (
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/backup-client/postgres"
+	"c8backup/pkg/backup-client/resources"
+)
+
+const (
+	ComponentIdentity   = "identity"
+	ComponentWebModeler = "web-modeler"
+	ComponentConnectors = "connectors"
+)
+
+const (
+	// databaseSuffix turns a component into the flag of its database, e.g. --identity-db
+	databaseSuffix = "-db"
+	// selectorSuffix turns a component into the flag selecting its kept resources, e.g. --connectors-selector
+	selectorSuffix = "-selector"
+)
+
+// ComponentType is a component beyond Zeebe and the webapps, backed up either by dumping its PostgreSQL database
+// with a Job or by keeping its Secrets and ConfigMaps.
+type ComponentType struct {
+	Name string
+	// Apps are the app.kubernetes.io/component labels of the Deployments stopped while the component is restored
+	Apps []string
+	// StatefulSets selects the StatefulSets stopped while the component is restored, e.g. Keycloak
+	StatefulSets string
+	// Database holds the defaults of the database, if the component is backed up with pg_dump
+	Database *postgres.Config
+	// Resources is set if the component is backed up by keeping the Secrets and ConfigMaps --<name>-selector selects
+	Resources bool
+}
+
+var componentTypes []ComponentType
+
+// RegisterComponent adds a component type. Its flags are derived from the name: --<name>-db for a database,
+// --<name>-selector for resources.
+func RegisterComponent(componentType ComponentType) {
+	name := componentType.Name
+	componentTypes = append(componentTypes, componentType)
+	AllComponents = append(AllComponents, name)
+	dependentApps[name] = componentType.Apps
+	if componentType.Database != nil {
+		componentRequirements[name] = []string{name + databaseSuffix}
+	}
+	if componentType.Resources {
+		componentRequirements[name] = []string{name + selectorSuffix}
+	}
+}
+
+// ComponentTypes returns the registered component types.
+func ComponentTypes() []ComponentType {
+	return componentTypes
+}
+
+func componentTypeOf(name string) (ComponentType, bool) {
+	for _, componentType := range componentTypes {
+		if componentType.Name == name {
+			return componentType, true
+		}
+	}
+	return ComponentType{}, false
+}
+
+func init() {
+	// The defaults are the ones of the Camunda Helm chart
+	RegisterComponent(ComponentType{
+		Name:         ComponentIdentity,
+		Apps:         []string{ComponentIdentity},
+		StatefulSets: "app.kubernetes.io/name=keycloak",
+		Database:     &postgres.Config{Database: "bitnami_keycloak", User: "bn_keycloak", PasswordKey: "password"},
+	})
+	RegisterComponent(ComponentType{
+		Name:     ComponentWebModeler,
+		Apps:     []string{"restapi", "webapp", "websockets"},
+		Database: &postgres.Config{Database: "web-modeler", User: "web-modeler", PasswordKey: "password"},
+	})
+	RegisterComponent(ComponentType{
+		Name:      ComponentConnectors,
+		Apps:      []string{ComponentConnectors},
+		Resources: true,
+	})
+}
+
+// registeredClient returns the backup client of a registered component, or nil if it isn't configured. The backups
+// are kept in the source namespace.
+func registeredClient(definition RestoreDefinition, name string) component.Component {
+	if definition.sourceKubeClient == nil {
+		return nil
+	}
+	if database, ok := definition.database(name); ok {
+		return postgres.NewClient(definition.sourceKubeClient, definition.sourceNamespace, database)
+	}
+	if selector := definition.resources[name]; selector != "" {
+		return resources.NewClient(definition.sourceKubeClient, definition.sourceNamespace, name, selector)
+	}
+	return nil
+}
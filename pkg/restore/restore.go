@@ -2,22 +2,19 @@ package restore
 
 import (
 	"context"
-	"flag"
+	"errors"
 	"fmt"
 	"log"
-	"path/filepath"
-	"time"
+	"os"
 
+	"c8backup/pkg/backup-client/component"
 	"c8backup/pkg/backup-client/elastic"
 	"c8backup/pkg/backup-client/webapps"
 	apps "k8s.io/api/apps/v1"
-	v1 "k8s.io/api/core/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/fields"
 	autov1 "k8s.io/client-go/applyconfigurations/autoscaling/v1"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 	//
 	// Uncomment to load all auth plugins
 	// _ "k8s.io/client-go/plugin/pkg/client/auth"
@@ -28,234 +25,368 @@ import (
 	// _ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 )
 
-var statefulsets *apps.StatefulSetList
-var deployments *apps.DeploymentList
-var pvcs *v1.PersistentVolumeClaimList
+// restoreRun is the state shared by the steps of one restore.
+type restoreRun struct {
+	kubeClient    *kubernetes.Clientset
+	elasticClient *elastic.Client
+	definition    RestoreDefinition
+	journal       *Journal
+}
 
-func Restore(namespace string, backupID int64, elasticUrl, operateUrl, tasklistUrl, optimizeUrl, snapshotRepository string) {
-	var kubeconfig *string
-	if home := homedir.HomeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
-	}
-	flag.Parse()
+type restoreStep struct {
+	step Step
+	run  func(ctx context.Context, r *restoreRun) error
+}
 
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-	if err != nil {
-		panic(err)
-	}
-	kubeClient, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err)
-	}
-	fmt.Println(backupID)
+var restoreSteps = []restoreStep{
+	{StepShutdownApps, func(ctx context.Context, r *restoreRun) error {
+		// We shut down related apps
+		err := shutdownApps(ctx, r.kubeClient, &r.journal.Plan)
+		if err != nil {
+			return err
+		}
+		return waitForDeploymentsStopped(ctx, r.kubeClient, &r.journal.Plan, r.jobRunner().timeout)
+	}},
+	{StepPreparePVCs, func(ctx context.Context, r *restoreRun) error {
+		return r.preparePVCs(ctx)
+	}},
+	{StepDeleteIndices, func(ctx context.Context, r *restoreRun) error {
+		return r.elasticClient.DeleteIndices(ctx, r.journal.Plan.Indices)
+	}},
+	{StepDeleteZeebeData, func(ctx context.Context, r *restoreRun) error {
+		return r.deleteZeebeData(ctx)
+	}},
+	{StepRestoreSnapshots, func(ctx context.Context, r *restoreRun) error {
+		// Restore the snapshots of the backups one by one, so a resume skips the ones that are already back
+		for _, snapshot := range r.journal.Plan.Snapshots {
+			if r.journal.snapshotRestored(snapshot) {
+				fmt.Println("snapshot already restored", snapshot)
+				continue
+			}
+			fmt.Println("restoring", snapshot)
+			err := r.elasticClient.RestoreSnapshots(ctx, []string{snapshot})
+			if err != nil {
+				return fmt.Errorf("error on snapshot restore %w", err)
+			}
+			r.journal.RestoredSnapshots = append(r.journal.RestoredSnapshots, snapshot)
+			err = r.journal.save(ctx, r.kubeClient)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{StepRestoreZeebe, func(ctx context.Context, r *restoreRun) error {
+		fmt.Println("restoring zeebe")
+		return r.restoreZeebe(ctx)
+	}},
+	{StepRestoreDatabases, func(ctx context.Context, r *restoreRun) error {
+		return r.restoreDatabases(ctx)
+	}},
+	{StepRestoreResources, func(ctx context.Context, r *restoreRun) error {
+		return r.restoreResources(ctx)
+	}},
+	{StepResetApps, func(ctx context.Context, r *restoreRun) error {
+		return r.scaleUp(ctx)
+	}},
+}
 
-	ctx := context.Background()
-	deployments, statefulsets = getRelatedApps(ctx, kubeClient, namespace)
-	if err != nil {
-		log.Fatalln(err)
-	}
+// Restore runs the steps of the restore, or resumes or aborts an unfinished one. The journal records the completed
+// steps, so that a failed restore can be resumed or aborted by a later run.
+func Restore(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition) error {
+	elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
 
-	// We gather all the snapshot names
-	elasticClient := elastic.NewElasticClient(elasticUrl, snapshotRepository)
-	optimizeClient, _ := webapps.NewBackupClient("optimize", optimizeUrl)
-	operateClient, _ := webapps.NewBackupClient("operate", operateUrl)
-	tasklistClient, _ := webapps.NewBackupClient("tasklist", tasklistUrl)
+	if definition.abort {
+		return abortRestore(ctx, kubeClient, definition.namespace)
+	}
 
-	snapshotNames := gatherSnapshotNames(ctx, backupID, elasticClient, []BackupGetter{optimizeClient, tasklistClient, operateClient})
-	fmt.Println(snapshotNames)
-	if !(len(snapshotNames) > 0) {
-		log.Fatalln("not enough snapshots")
+	var journal *Journal
+	var err error
+	if definition.resume {
+		journal, err = loadJournal(ctx, kubeClient, definition.namespace)
+		if err != nil {
+			return err
+		}
+		if journal == nil {
+			return fmt.Errorf("no unfinished restore found in namespace %s", definition.namespace)
+		}
+		fmt.Printf("resuming restore of backup %d started at %s, completed steps: %v\n", journal.Plan.BackupID, journal.StartedAt, journal.CompletedSteps)
+	} else {
+		plan, err := resolvePlan(ctx, kubeClient, elasticClient, definition)
+		if err != nil || plan == nil {
+			return err
+		}
+		existing, err := loadJournal(ctx, kubeClient, plan.Namespace)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return fmt.Errorf("an unfinished restore of backup %d exists in namespace %s, use --resume or --abort", existing.Plan.BackupID, plan.Namespace)
+		}
+		journal = newJournal(plan)
+		err = journal.save(ctx, kubeClient)
+		if err != nil {
+			return err
+		}
 	}
 
-	// We shut down related apps
-	err = shutdownApps(ctx, kubeClient, namespace)
-	if err != nil {
-		log.Fatalln(err)
+	run := &restoreRun{
+		kubeClient:    kubeClient,
+		elasticClient: elasticClient,
+		definition:    definition,
+		journal:       journal,
+	}
+	for _, step := range restoreSteps {
+		if journal.completed(step.step) {
+			fmt.Println("skipping completed step", step.step)
+			continue
+		}
+		fmt.Println("running step", step.step)
+		err = step.run(ctx, run)
+		if err != nil {
+			return fmt.Errorf("step %s failed: %w. Fix the cause and run restore --resume, or restore --abort to scale the apps back", step.step, err)
+		}
+		journal.CompletedSteps = append(journal.CompletedSteps, step.step)
+		err = journal.save(ctx, kubeClient)
+		if err != nil {
+			return err
+		}
 	}
 
-	// Delete everything in elasticsearch
-	err = elasticClient.DeleteAllIndices(ctx)
+	err = journal.delete(ctx, kubeClient)
 	if err != nil {
-		log.Fatalln(err)
-		return
+		log.Println("unable to delete restore journal", err)
 	}
+	fmt.Println("restore of backup", journal.Plan.BackupID, "done")
+	return nil
+}
 
-	err = deleteZeebeData(ctx, kubeClient, namespace, false)
-	if err != nil {
-		log.Fatalln(err)
+// resolvePlan returns the plan to run, or nil if the plan should only be shown or saved.
+func resolvePlan(ctx context.Context, kubeClient *kubernetes.Clientset, elasticClient *elastic.Client, definition RestoreDefinition) (*Plan, error) {
+	var plan *Plan
+	var err error
+	if definition.applyPlanFile != "" {
+		plan, err = ReadPlan(definition.applyPlanFile)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("applying plan", definition.applyPlanFile)
+	} else {
+		plan, err = NewPlan(ctx, kubeClient, elasticClient, definition)
+		if err != nil {
+			return nil, err
+		}
 	}
+	plan.Print(os.Stdout)
 
-	// Restore the snapshots of the backups
-	fmt.Printf("restoring %v\n", snapshotNames)
-	err = elasticClient.RestoreSnapshots(ctx, snapshotNames)
-	if err != nil {
-		fmt.Println("error on snapshot restore", err)
-		return
+	if definition.planFile != "" {
+		err = plan.Write(definition.planFile)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("plan written to", definition.planFile)
+		return nil, nil
+	}
+	if definition.dryRun {
+		return nil, nil
 	}
+	return plan, nil
+}
 
-	fmt.Println("restoring zeebe")
-	err = restoreZeebe(ctx, kubeClient, namespace, backupID, false)
+// abortRestore scales the apps back to the replicas recorded in the journal and forgets the unfinished restore.
+// The data is left as is, it may be partially deleted or restored.
+func abortRestore(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string) error {
+	journal, err := loadJournal(ctx, kubeClient, namespace)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
+	if journal == nil {
+		return fmt.Errorf("no unfinished restore found in namespace %s", namespace)
+	}
+	fmt.Printf("aborting restore of backup %d, completed steps: %v\n", journal.Plan.BackupID, journal.CompletedSteps)
 
-	// Give it some time before scaling up
-	fmt.Println("sleeping 10 seconds")
-	time.Sleep(time.Second * 10)
-
-	// We reset the apps
-	errorList := resetApps(ctx, kubeClient)
+	errorList := resetApps(ctx, kubeClient, &journal.Plan)
 	for _, err := range errorList {
 		fmt.Println(err)
 	}
 	if len(errorList) > 0 {
-		log.Fatalln("there were errors")
+		return fmt.Errorf("there were errors scaling the apps back, the journal is kept")
 	}
+	return journal.delete(ctx, kubeClient)
 }
 
-func restoreZeebe(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string, backupID int64, alreadyStarted bool) error {
-	zeebe := statefulsets.Items[0].DeepCopy()
-	if !alreadyStarted {
-		for _, item := range pvcs.Items {
-			restoreJob := NewRestoreJob(item, zeebe, backupID)
-			create, err := kubeClient.BatchV1().Jobs(namespace).Create(ctx, restoreJob, metav1.CreateOptions{FieldManager: "c8-backup"})
-			if err != nil {
-				return err
-			}
-			fmt.Println("Created restore job", create.Name)
-		}
+func (r *restoreRun) restoreZeebe(ctx context.Context) error {
+	kubeClient := r.kubeClient
+	plan := &r.journal.Plan
+	namespace := plan.Namespace
+	if plan.Zeebe == nil {
+		return nil
 	}
-
-	// "WATCH" jobs and delete them once they finish recursively
-	time.Sleep(time.Second)
-	// Create delete job per PVC
-	jobs, err := kubeClient.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "job=restore-zeebe",
-	})
+	zeebe, _, err := r.zeebeStatefulSet(ctx)
 	if err != nil {
 		return err
 	}
-
-	runningJobs := 0
-	for _, item := range jobs.Items {
-		if item.Status.CompletionTime != nil {
-			err := kubeClient.BatchV1().Jobs(namespace).Delete(ctx, item.Name, metav1.DeleteOptions{})
-			if err != nil {
-				fmt.Println("unable to delete job", err)
-			}
-		} else {
-			runningJobs += 1
+	store := r.definition.backupStore
+	if store == nil {
+		store, err = DetectBackupStore(zeebe)
+		if err != nil {
+			return err
 		}
 	}
-	if runningJobs > 0 {
-		time.Sleep(time.Second)
-		return restoreZeebe(ctx, kubeClient, namespace, backupID, true)
-	} else {
-		return nil
-	}
+	fmt.Println("restoring zeebe from backup store", store.Type())
 
+	var jobs []*batchv1.Job
+	for _, broker := range plan.Zeebe.Brokers {
+		job := NewRestoreJob(broker.PVC, broker.NodeID, zeebe, plan.BackupID, store, r.definition.jobOverrides)
+		err = pinToVolume(ctx, kubeClient, &job.Spec.Template.Spec, namespace, broker.PVC)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	return r.jobRunner().Run(ctx, "job=restore-zeebe", jobs)
 }
 
-func deleteZeebeData(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string, alreadyStarted bool) error {
-	var err error
-	// Get Zeebe PVCS
-	if !alreadyStarted {
+func (r *restoreRun) deleteZeebeData(ctx context.Context) error {
+	plan := &r.journal.Plan
+	if plan.Zeebe == nil {
+		return nil
+	}
+	zeebe, inTarget, err := r.zeebeStatefulSet(ctx)
+	if err != nil {
+		return err
+	}
+	if inTarget {
+		err = waitForBrokersGone(ctx, r.kubeClient, zeebe, r.jobRunner().timeout)
+		if err != nil {
+			return err
+		}
+	}
 
-		pvcs, err = kubeClient.CoreV1().PersistentVolumeClaims(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app.kubernetes.io/app=zeebe"})
+	var jobs []*batchv1.Job
+	for _, broker := range plan.Zeebe.Brokers {
+		if broker.Create {
+			// Freshly created, nothing to wipe
+			continue
+		}
+		job := NewDeletionJob(broker.PVC, zeebe, r.definition.jobOverrides)
+		err = pinToVolume(ctx, r.kubeClient, &job.Spec.Template.Spec, plan.Namespace, broker.PVC)
 		if err != nil {
 			return err
 		}
+		jobs = append(jobs, job)
+	}
+	return r.jobRunner().Run(ctx, "job=delete-zeebe", jobs)
+}
+
+// zeebeStatefulSet returns the broker StatefulSet of the plan, see zeebeStatefulSet.
+func (r *restoreRun) zeebeStatefulSet(ctx context.Context) (*apps.StatefulSet, bool, error) {
+	definition := r.definition
+	definition.zeebeStatefulSet = r.journal.Plan.Zeebe.StatefulSet
+	definition.fresh = r.journal.Plan.Fresh
+	return zeebeStatefulSet(ctx, r.kubeClient, definition, r.journal.Plan.Namespace)
+}
 
-		for _, pvc := range pvcs.Items {
-			pvcName := pvc.Name
-			job := NewDeletionJob(pvcName, namespace)
-			create, err := kubeClient.BatchV1().Jobs(namespace).Create(ctx, job, metav1.CreateOptions{FieldManager: "c8-backup"})
+// scaleUp starts Zeebe first and waits until its brokers are ready and every partition has a leader. Only then the
+// Deployments are scaled up, and each of them has to become available and, for webapps with a known mgmt endpoint,
+// report UP. The error lists every component that did not become healthy.
+func (r *restoreRun) scaleUp(ctx context.Context) error {
+	plan := &r.journal.Plan
+	timeout := r.definition.healthTimeout
+	if timeout == 0 {
+		timeout = DefaultHealthTimeout
+	}
+
+	// Until the deployments are scaled up, whatever scales them stays paused
+	stillPaused := func(errorList []error) error {
+		return fmt.Errorf("%w. The hpas and gitops of the plan stay suspended, restore --resume or --abort resumes them", errors.Join(errorList...))
+	}
+	errorList := scaleStatefulSets(ctx, r.kubeClient, plan)
+	if len(errorList) > 0 {
+		return stillPaused(errorList)
+	}
+	for _, sts := range plan.StatefulSets {
+		if sts.Replicas == 0 {
+			continue
+		}
+		err := waitHealthy(ctx, "statefulset "+sts.Name, timeout, func(ctx context.Context) error {
+			err := statefulSetReady(ctx, r.kubeClient, plan.Namespace, sts.Name)
+			if err != nil || plan.Zeebe == nil || plan.Zeebe.StatefulSet != sts.Name {
+				return err
+			}
+			zeebe, _, err := r.zeebeStatefulSet(ctx)
 			if err != nil {
 				return err
 			}
-			fmt.Println("Created delete job", create.Name)
+			return zeebeTopologyHealthy(ctx, r.kubeClient, zeebe, plan.Zeebe)
+		})
+		if err != nil {
+			errorList = append(errorList, err)
 		}
 	}
-
-	// "WATCH" jobs and delete them once they finish recursively
-	time.Sleep(time.Second)
-	// Create delete job per PVC
-	jobs, err := kubeClient.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{
-		LabelSelector: "job=delete-zeebe",
-	})
-	if err != nil {
-		return err
+	if len(errorList) > 0 {
+		// The webapps would only fail to import from Zeebe
+		return stillPaused(errorList)
 	}
+	fmt.Println("zeebe is healthy, scaling up the deployments")
 
-	runningJobs := 0
-	for _, item := range jobs.Items {
-		if item.Status.CompletionTime != nil {
-			err := kubeClient.BatchV1().Jobs(namespace).Delete(ctx, item.Name, metav1.DeleteOptions{})
-			if err != nil {
-				fmt.Println("unable to delete job", err)
+	errorList = scaleDeployments(ctx, r.kubeClient, plan)
+	if len(errorList) > 0 {
+		return stillPaused(errorList)
+	}
+	errorList = resumeScaling(ctx, r.kubeClient, plan)
+	mgmtURLs := map[string]string{
+		webapps.OperateApp:  r.definition.operateURL,
+		webapps.TasklistApp: r.definition.tasklistURL,
+		webapps.OptimizeApp: r.definition.optimizeURL,
+	}
+	for _, deployment := range plan.Deployments {
+		if deployment.Replicas == 0 {
+			continue
+		}
+		name := "deployment " + deployment.Name
+		if deployment.Component != "" {
+			name = deployment.Component + " (" + name + ")"
+		}
+		err := waitHealthy(ctx, name, timeout, func(ctx context.Context) error {
+			err := deploymentAvailable(ctx, r.kubeClient, plan.Namespace, deployment.Name)
+			if err != nil || mgmtURLs[deployment.Component] == "" {
+				return err
 			}
-		} else {
-			runningJobs += 1
+			return webappHealthy(ctx, mgmtURLs[deployment.Component])
+		})
+		if err != nil {
+			errorList = append(errorList, err)
 		}
 	}
-	if runningJobs > 0 {
-		time.Sleep(time.Second)
-		return deleteZeebeData(ctx, kubeClient, namespace, true)
-	} else {
-		return nil
-	}
+	return errors.Join(errorList...)
 }
 
-type BackupGetter interface {
-	GetBackup(context.Context, int64) (*webapps.BackupResponse, error)
+func (r *restoreRun) jobRunner() *JobRunner {
+	return NewJobRunner(r.kubeClient, r.journal.Plan.Namespace, r.definition.jobTimeout)
 }
 
-func gatherSnapshotNames(ctx context.Context, backupID int64, elasticClient *elastic.Client, clients []BackupGetter) []string {
+// gatherSnapshotNames collects the snapshots to restore for the backup of the components. It fails if the backup of
+// one of them can't be restored.
+func gatherSnapshotNames(ctx context.Context, backupID int64, components []component.Component) ([]string, error) {
 	var snapshotNames []string
-	for _, client := range clients {
-		backupResp, err := client.GetBackup(ctx, backupID)
+	for _, c := range components {
+		snapshots, err := c.RestorePrerequisites(ctx, backupID)
 		if err != nil {
-			return nil
+			return nil, err
 		}
-
-		for _, detail := range backupResp.Details {
-			snapshotNames = append(snapshotNames, detail.SnapshotName)
-		}
-	}
-
-	// Get Zeebe snapshots
-	backupResp, err := elasticClient.GetBackup(ctx, backupID)
-	if err != nil {
-		return nil
-	}
-	for _, backup := range backupResp.Snapshots {
-		snapshotNames = append(snapshotNames, backup.Snapshot)
+		snapshotNames = append(snapshotNames, snapshots...)
 	}
-	return snapshotNames
+	return snapshotNames, nil
 }
 
-func getRelatedApps(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string) (*apps.DeploymentList, *apps.StatefulSetList) {
-	var err error
-	deployments, err = kubeClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+func shutdownApps(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) error {
+	namespace := plan.Namespace
+	err := pauseScaling(ctx, kubeClient, plan)
 	if err != nil {
-		log.Println(err)
-		return nil, nil
-	}
-	statefulsets, err = kubeClient.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{
-		FieldSelector: fields.OneTermEqualSelector("metadata.name", "zeebe").String(),
-	})
-	if err != nil {
-		log.Println(err)
-		return nil, nil
+		return err
 	}
-
-	return deployments, statefulsets
-}
-
-func shutdownApps(ctx context.Context, kubeClient *kubernetes.Clientset, namespace string) error {
-	for _, deployment := range deployments.Items {
+	for _, deployment := range plan.Deployments {
 		fmt.Println(deployment.Name)
 		scaleConfig := autov1.Scale()
 		scaleConfig.Spec = autov1.ScaleSpec()
@@ -271,7 +402,7 @@ func shutdownApps(ctx context.Context, kubeClient *kubernetes.Clientset, namespa
 		fmt.Println("SCALED DEPLOYMENT", scale.String())
 	}
 
-	for _, sts := range statefulsets.Items {
+	for _, sts := range plan.StatefulSets {
 		scaleConfig := autov1.Scale()
 		scaleConfig.Spec = autov1.ScaleSpec()
 		scaleConfig.Spec.WithReplicas(0)
@@ -289,13 +420,23 @@ func shutdownApps(ctx context.Context, kubeClient *kubernetes.Clientset, namespa
 	return nil
 }
 
-func resetApps(ctx context.Context, kubeClient *kubernetes.Clientset) []error {
+// resetApps scales the apps back to their recorded replicas without waiting for them, and resumes their HPAs
+// and GitOps controllers.
+func resetApps(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) []error {
+	errorList := append(scaleStatefulSets(ctx, kubeClient, plan), scaleDeployments(ctx, kubeClient, plan)...)
+	if len(errorList) > 0 {
+		return errorList
+	}
+	return resumeScaling(ctx, kubeClient, plan)
+}
+
+func scaleDeployments(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) []error {
 	var errors []error
-	for _, deployment := range deployments.Items {
+	for _, deployment := range plan.Deployments {
 		scaleConfig := autov1.Scale()
 		scaleConfig.Spec = autov1.ScaleSpec()
-		scaleConfig.Spec.WithReplicas(*deployment.Spec.Replicas)
-		scale, err := kubeClient.AppsV1().Deployments(deployment.Namespace).ApplyScale(ctx, deployment.Name, scaleConfig, metav1.ApplyOptions{
+		scaleConfig.Spec.WithReplicas(deployment.Replicas)
+		scale, err := kubeClient.AppsV1().Deployments(plan.Namespace).ApplyScale(ctx, deployment.Name, scaleConfig, metav1.ApplyOptions{
 			FieldManager: "c8-backup",
 			Force:        true,
 		})
@@ -304,12 +445,16 @@ func resetApps(ctx context.Context, kubeClient *kubernetes.Clientset) []error {
 		}
 		fmt.Println("SCALED DEPLOYMENT", scale.String())
 	}
+	return errors
+}
 
-	for _, sts := range statefulsets.Items {
+func scaleStatefulSets(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) []error {
+	var errors []error
+	for _, sts := range plan.StatefulSets {
 		scaleConfig := autov1.Scale()
 		scaleConfig.Spec = autov1.ScaleSpec()
-		scaleConfig.Spec.WithReplicas(*sts.Spec.Replicas)
-		scale, err := kubeClient.AppsV1().StatefulSets(sts.Namespace).ApplyScale(ctx, sts.Name, scaleConfig, metav1.ApplyOptions{
+		scaleConfig.Spec.WithReplicas(sts.Replicas)
+		scale, err := kubeClient.AppsV1().StatefulSets(plan.Namespace).ApplyScale(ctx, sts.Name, scaleConfig, metav1.ApplyOptions{
 			FieldManager: "c8-backup",
 			Force:        true,
 		})
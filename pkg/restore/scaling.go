@@ -0,0 +1,405 @@
+package restore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	apps "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultAppSelector selects the Deployments of the Camunda Helm chart.
+const DefaultAppSelector = "app.kubernetes.io/part-of=camunda-platform"
+
+// DefaultArgoCDNamespace is where Argo CD Applications are looked up, unless the tracking id names another one.
+const DefaultArgoCDNamespace = "argocd"
+
+const (
+	originalReplicasAnnotation = "c8backup/original-replicas"
+	originalBehaviorAnnotation = "c8backup/original-behavior"
+
+	argoTrackingAnnotation  = "argocd.argoproj.io/tracking-id"
+	argoInstanceLabel       = "app.kubernetes.io/instance"
+	argoSkipReconcile       = "argocd.argoproj.io/skip-reconcile"
+	fluxKustomizeLabel      = "kustomize.toolkit.fluxcd.io/name"
+	fluxHelmLabel           = "helm.toolkit.fluxcd.io/name"
+	fluxReconcileAnnotation = "kustomize.toolkit.fluxcd.io/reconcile"
+	fluxDriftAnnotation     = "helm.toolkit.fluxcd.io/driftDetection"
+)
+
+const (
+	GitOpsArgoCD = "argocd"
+	GitOpsFlux   = "flux"
+)
+
+const (
+	kindDeployment  = "Deployment"
+	kindStatefulSet = "StatefulSet"
+)
+
+// PausedHPA is a HorizontalPodAutoscaler of a scaled workload. Scaling is disabled in its behavior during the
+// restore, so it doesn't scale the workload back up, and the recorded behavior is put back afterwards.
+type PausedHPA struct {
+	Name        string                                         `json:"name"`
+	Target      string                                         `json:"target"`
+	MinReplicas *int32                                         `json:"minReplicas,omitempty"`
+	MaxReplicas int32                                          `json:"maxReplicas"`
+	Behavior    *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+}
+
+// SuspendedGitOps is a GitOps controller that would revert the scaling. For Argo CD it is the Application, which
+// gets skip-reconcile. For Flux it is the workload, which gets the annotations Flux skips resources with.
+type SuspendedGitOps struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// listApps returns the Deployments matching the selector. If the default selector matches nothing, e.g. for
+// installations without the Helm chart labels, it falls back to the Deployments named like a Camunda component.
+func listApps(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, selector string) ([]apps.Deployment, []string, error) {
+	if selector == "" {
+		selector = DefaultAppSelector
+	}
+	deployments, err := kubeClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(deployments.Items) > 0 || selector != DefaultAppSelector {
+		return deployments.Items, nil, nil
+	}
+
+	deployments, err = kubeClient.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	var matching []apps.Deployment
+	for _, deployment := range deployments.Items {
+		if dependsOn(deployment, AllComponents) {
+			matching = append(matching, deployment)
+		}
+	}
+	warning := fmt.Sprintf("no deployments match %s, selected %d deployments by name, pass --app-selector", selector, len(matching))
+	return matching, []string{warning}, nil
+}
+
+// originalReplicas prefers the replicas recorded by an earlier, interrupted restore over the current ones,
+// which are 0 then.
+func originalReplicas(meta metav1.ObjectMeta, replicas *int32) int32 {
+	if recorded, err := strconv.ParseInt(meta.Annotations[originalReplicasAnnotation], 10, 32); err == nil {
+		return int32(recorded)
+	}
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// findHPAs returns the HorizontalPodAutoscalers scaling a workload of the plan.
+func findHPAs(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) ([]PausedHPA, error) {
+	hpas, err := kubeClient.AutoscalingV2().HorizontalPodAutoscalers(plan.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	targets := map[string]bool{}
+	for _, deployment := range plan.Deployments {
+		targets[kindDeployment+"/"+deployment.Name] = true
+	}
+	for _, sts := range plan.StatefulSets {
+		targets[kindStatefulSet+"/"+sts.Name] = true
+	}
+
+	var paused []PausedHPA
+	for _, hpa := range hpas.Items {
+		target := hpa.Spec.ScaleTargetRef.Kind + "/" + hpa.Spec.ScaleTargetRef.Name
+		if !targets[target] {
+			continue
+		}
+		behavior := hpa.Spec.Behavior
+		if recorded, ok := hpa.Annotations[originalBehaviorAnnotation]; ok {
+			// Paused by an interrupted restore
+			behavior = nil
+			err = json.Unmarshal([]byte(recorded), &behavior)
+			if err != nil {
+				return nil, fmt.Errorf("error unmarshalling %s of hpa %s: %w", originalBehaviorAnnotation, hpa.Name, err)
+			}
+		}
+		paused = append(paused, PausedHPA{
+			Name:        hpa.Name,
+			Target:      target,
+			MinReplicas: hpa.Spec.MinReplicas,
+			MaxReplicas: hpa.Spec.MaxReplicas,
+			Behavior:    behavior,
+		})
+	}
+	return paused, nil
+}
+
+// findGitOps detects Argo CD and Flux managing a workload of the plan. An Argo CD Application is only recognized
+// if it exists, app.kubernetes.io/instance is set by plain Helm installations as well.
+func findGitOps(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan, argoCDNamespace string) ([]SuspendedGitOps, error) {
+	if argoCDNamespace == "" {
+		argoCDNamespace = DefaultArgoCDNamespace
+	}
+	var suspended []SuspendedGitOps
+	seen := map[SuspendedGitOps]bool{}
+	forbidden := map[string]bool{}
+	add := func(gitOps SuspendedGitOps) {
+		if !seen[gitOps] {
+			seen[gitOps] = true
+			suspended = append(suspended, gitOps)
+		}
+	}
+
+	err := forEachWorkload(ctx, kubeClient, plan, func(kind string, meta metav1.ObjectMeta) error {
+		if meta.Labels[fluxKustomizeLabel] != "" || meta.Labels[fluxHelmLabel] != "" {
+			add(SuspendedGitOps{Kind: GitOpsFlux, Namespace: meta.Namespace, Name: kind + "/" + meta.Name})
+		}
+
+		appNamespace, appName := argoCDNamespace, meta.Labels[argoInstanceLabel]
+		if trackingID := meta.Annotations[argoTrackingAnnotation]; trackingID != "" {
+			appName, _, _ = strings.Cut(trackingID, ":")
+		}
+		if namespace, name, found := strings.Cut(appName, "_"); found {
+			// Applications in any namespace are tracked as <namespace>_<name>
+			appNamespace, appName = namespace, name
+		}
+		if appName == "" {
+			return nil
+		}
+		annotations, err := argoApplicationAnnotations(ctx, kubeClient, appNamespace, appName)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if apierrors.IsForbidden(err) {
+			// Argo CD may be run by another team, without access the restore can't suspend it but goes on
+			if !forbidden[appNamespace+"/"+appName] {
+				forbidden[appNamespace+"/"+appName] = true
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("not allowed to read argo cd application %s/%s, it is not suspended and may scale the workloads back up during the restore", appNamespace, appName))
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("unable to check for argo cd application %s/%s of %s %s: %w", appNamespace, appName, kind, meta.Name, err)
+		}
+		if annotations[argoSkipReconcile] == "true" {
+			// Already suspended by someone else, they resume it
+			return nil
+		}
+		add(SuspendedGitOps{Kind: GitOpsArgoCD, Namespace: appNamespace, Name: appName})
+		return nil
+	})
+	return suspended, err
+}
+
+// pauseScaling stops whatever would scale the workloads of the plan back up and records their replicas on them.
+func pauseScaling(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) error {
+	for _, gitOps := range plan.GitOps {
+		fmt.Printf("suspending %s for %s/%s\n", gitOps.Kind, gitOps.Namespace, gitOps.Name)
+		err := setGitOpsSuspended(ctx, kubeClient, gitOps, true)
+		if err != nil {
+			return fmt.Errorf("unable to suspend %s for %s: %w", gitOps.Kind, gitOps.Name, err)
+		}
+	}
+
+	hpas := kubeClient.AutoscalingV2().HorizontalPodAutoscalers(plan.Namespace)
+	for _, paused := range plan.HPAs {
+		hpa, err := hpas.Get(ctx, paused.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := hpa.Annotations[originalBehaviorAnnotation]; !ok {
+			recorded, err := json.Marshal(paused.Behavior)
+			if err != nil {
+				return err
+			}
+			metav1.SetMetaDataAnnotation(&hpa.ObjectMeta, originalBehaviorAnnotation, string(recorded))
+		}
+		disabled := autoscalingv2.DisabledPolicySelect
+		hpa.Spec.Behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{
+			ScaleUp:   &autoscalingv2.HPAScalingRules{SelectPolicy: &disabled},
+			ScaleDown: &autoscalingv2.HPAScalingRules{SelectPolicy: &disabled},
+		}
+		_, err = hpas.Update(ctx, hpa, metav1.UpdateOptions{FieldManager: "c8-backup"})
+		if err != nil {
+			return fmt.Errorf("unable to pause hpa %s: %w", paused.Name, err)
+		}
+		fmt.Println("paused hpa", paused.Name)
+	}
+
+	for _, deployment := range plan.Deployments {
+		err := patchAnnotations(ctx, kubeClient, plan.Namespace, kindDeployment, deployment.Name, map[string]*string{
+			originalReplicasAnnotation: stringPtr(strconv.Itoa(int(deployment.Replicas))),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, sts := range plan.StatefulSets {
+		err := patchAnnotations(ctx, kubeClient, plan.Namespace, kindStatefulSet, sts.Name, map[string]*string{
+			originalReplicasAnnotation: stringPtr(strconv.Itoa(int(sts.Replicas))),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeScaling undoes pauseScaling, after the workloads are scaled back up.
+func resumeScaling(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan) []error {
+	var errors []error
+	for _, deployment := range plan.Deployments {
+		err := patchAnnotations(ctx, kubeClient, plan.Namespace, kindDeployment, deployment.Name, map[string]*string{originalReplicasAnnotation: nil})
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	for _, sts := range plan.StatefulSets {
+		err := patchAnnotations(ctx, kubeClient, plan.Namespace, kindStatefulSet, sts.Name, map[string]*string{originalReplicasAnnotation: nil})
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	hpas := kubeClient.AutoscalingV2().HorizontalPodAutoscalers(plan.Namespace)
+	for _, paused := range plan.HPAs {
+		hpa, err := hpas.Get(ctx, paused.Name, metav1.GetOptions{})
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		hpa.Spec.Behavior = paused.Behavior
+		delete(hpa.Annotations, originalBehaviorAnnotation)
+		_, err = hpas.Update(ctx, hpa, metav1.UpdateOptions{FieldManager: "c8-backup"})
+		if err != nil {
+			errors = append(errors, fmt.Errorf("unable to resume hpa %s: %w", paused.Name, err))
+			continue
+		}
+		fmt.Println("resumed hpa", paused.Name)
+	}
+
+	for _, gitOps := range plan.GitOps {
+		err := setGitOpsSuspended(ctx, kubeClient, gitOps, false)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("unable to resume %s for %s: %w", gitOps.Kind, gitOps.Name, err))
+			continue
+		}
+		fmt.Printf("resumed %s for %s/%s\n", gitOps.Kind, gitOps.Namespace, gitOps.Name)
+	}
+	return errors
+}
+
+func setGitOpsSuspended(ctx context.Context, kubeClient *kubernetes.Clientset, gitOps SuspendedGitOps, suspended bool) error {
+	switch gitOps.Kind {
+	case GitOpsArgoCD:
+		var value *string
+		if suspended {
+			value = stringPtr("true")
+		}
+		return patchArgoApplicationAnnotations(ctx, kubeClient, gitOps.Namespace, gitOps.Name, map[string]*string{argoSkipReconcile: value})
+	case GitOpsFlux:
+		kind, name, _ := strings.Cut(gitOps.Name, "/")
+		var value *string
+		if suspended {
+			value = stringPtr("disabled")
+		}
+		return patchAnnotations(ctx, kubeClient, gitOps.Namespace, kind, name, map[string]*string{
+			fluxReconcileAnnotation: value,
+			fluxDriftAnnotation:     value,
+		})
+	default:
+		return fmt.Errorf("unknown gitops controller %s", gitOps.Kind)
+	}
+}
+
+// forEachWorkload calls f with the metadata of every Deployment and StatefulSet of the plan.
+func forEachWorkload(ctx context.Context, kubeClient *kubernetes.Clientset, plan *Plan, f func(kind string, meta metav1.ObjectMeta) error) error {
+	for _, target := range plan.Deployments {
+		deployment, err := kubeClient.AppsV1().Deployments(plan.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		err = f(kindDeployment, deployment.ObjectMeta)
+		if err != nil {
+			return err
+		}
+	}
+	for _, target := range plan.StatefulSets {
+		sts, err := kubeClient.AppsV1().StatefulSets(plan.Namespace).Get(ctx, target.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		err = f(kindStatefulSet, sts.ObjectMeta)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// patchAnnotations sets the annotations of the workload, nil values remove them.
+func patchAnnotations(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, kind, name string, annotations map[string]*string) error {
+	patch, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	options := metav1.PatchOptions{FieldManager: "c8-backup"}
+	switch kind {
+	case kindDeployment:
+		_, err = kubeClient.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch, options)
+	case kindStatefulSet:
+		_, err = kubeClient.AppsV1().StatefulSets(namespace).Patch(ctx, name, types.MergePatchType, patch, options)
+	default:
+		err = fmt.Errorf("unable to annotate %s %s", kind, name)
+	}
+	return err
+}
+
+// The Argo CD Application CRD is not part of client-go, it is requested by path.
+func argoApplicationPath(namespace, name string) string {
+	return fmt.Sprintf("/apis/argoproj.io/v1alpha1/namespaces/%s/applications/%s", namespace, name)
+}
+
+func argoApplicationAnnotations(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, name string) (map[string]string, error) {
+	data, err := kubeClient.Discovery().RESTClient().Get().AbsPath(argoApplicationPath(namespace, name)).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var application metav1.PartialObjectMetadata
+	err = json.Unmarshal(data, &application)
+	if err != nil {
+		return nil, err
+	}
+	return application.Annotations, nil
+}
+
+func patchArgoApplicationAnnotations(ctx context.Context, kubeClient *kubernetes.Clientset, namespace, name string, annotations map[string]*string) error {
+	patch, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	return kubeClient.Discovery().RESTClient().Patch(types.MergePatchType).
+		AbsPath(argoApplicationPath(namespace, name)).
+		Param("fieldManager", "c8-backup").
+		Body(patch).
+		Do(ctx).
+		Error()
+}
+
+func annotationsPatch(annotations map[string]*string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{"annotations": annotations},
+	})
+}
+
+func stringPtr(value string) *string {
+	return &value
+}
@@ -0,0 +1,155 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// JobOverrides replace what the delete and restore Jobs inherit from the Zeebe StatefulSet pod template,
+// and configure the helper image wiping the PVCs.
+type JobOverrides struct {
+	NodeSelector map[string]string
+	Tolerations  []corev1.Toleration
+	RunAsUser    *int64
+	FSGroup      *int64
+	Resources    corev1.ResourceRequirements
+
+	HelperImage        string
+	ImageRegistry      string
+	ImagePullSecrets   []string
+	WipeWithZeebeImage bool
+}
+
+// inheritPodTemplate schedules the Job pod like a broker pod: same node selection, tolerations, security
+// context, pull secrets and resources, so it can run on hardened clusters and access the broker's files.
+func inheritPodTemplate(spec *corev1.PodSpec, sts *apps.StatefulSet, overrides JobOverrides) {
+	template := sts.Spec.Template.Spec.DeepCopy()
+	broker := template.Containers[0]
+
+	spec.NodeSelector = template.NodeSelector
+	spec.Affinity = template.Affinity
+	spec.Tolerations = template.Tolerations
+	spec.SecurityContext = template.SecurityContext
+	spec.ImagePullSecrets = template.ImagePullSecrets
+	spec.PriorityClassName = template.PriorityClassName
+	container := &spec.Containers[0]
+	container.SecurityContext = broker.SecurityContext
+	container.Resources = broker.Resources
+
+	if len(overrides.NodeSelector) > 0 {
+		spec.NodeSelector = overrides.NodeSelector
+	}
+	if len(overrides.Tolerations) > 0 {
+		spec.Tolerations = overrides.Tolerations
+	}
+	if overrides.RunAsUser != nil || overrides.FSGroup != nil {
+		if spec.SecurityContext == nil {
+			spec.SecurityContext = &corev1.PodSecurityContext{}
+		}
+		if overrides.RunAsUser != nil {
+			spec.SecurityContext.RunAsUser = overrides.RunAsUser
+			if container.SecurityContext != nil {
+				container.SecurityContext.RunAsUser = overrides.RunAsUser
+			}
+		}
+		if overrides.FSGroup != nil {
+			spec.SecurityContext.FSGroup = overrides.FSGroup
+		}
+	}
+	if len(overrides.Resources.Requests) > 0 || len(overrides.Resources.Limits) > 0 {
+		container.Resources = overrides.Resources
+	}
+	for _, secret := range overrides.ImagePullSecrets {
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secret})
+	}
+}
+
+// pinToVolume requires the nodes the PersistentVolume of the claim is reachable from, e.g. its zone. The node
+// affinity of the broker is replaced, since the volume decides where the pod can run.
+func pinToVolume(ctx context.Context, kubeClient *kubernetes.Clientset, spec *corev1.PodSpec, namespace, pvcName string) error {
+	pvc, err := kubeClient.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, pvcName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pvc.Spec.VolumeName == "" {
+		return nil
+	}
+	pv, err := kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return nil
+	}
+	if spec.Affinity == nil {
+		spec.Affinity = &corev1.Affinity{}
+	} else {
+		spec.Affinity = spec.Affinity.DeepCopy()
+	}
+	spec.Affinity.NodeAffinity = &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: pv.Spec.NodeAffinity.Required.DeepCopy(),
+	}
+	return nil
+}
+
+// waitForBrokersGone waits until no pod of the StatefulSet is left, so that ReadWriteOnce volumes are detached
+// before the Jobs mount them on another node.
+func waitForBrokersGone(ctx context.Context, kubeClient *kubernetes.Clientset, sts *apps.StatefulSet, timeout time.Duration) error {
+	selector := metav1.FormatLabelSelector(sts.Spec.Selector)
+	return wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := kubeClient.CoreV1().Pods(sts.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return false, err
+		}
+		if len(pods.Items) > 0 {
+			fmt.Printf("waiting for %d pods of %s to terminate\n", len(pods.Items), sts.Name)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// ParseTolerations parses tolerations given as key[=value]:effect, e.g. dedicated=zeebe:NoSchedule.
+func ParseTolerations(values []string) ([]corev1.Toleration, error) {
+	var tolerations []corev1.Toleration
+	for _, value := range values {
+		keyValue, effect, found := strings.Cut(value, ":")
+		if !found {
+			return nil, fmt.Errorf("toleration %q has no effect, use key[=value]:effect", value)
+		}
+		toleration := corev1.Toleration{Effect: corev1.TaintEffect(effect), Operator: corev1.TolerationOpExists}
+		key, tolerationValue, hasValue := strings.Cut(keyValue, "=")
+		toleration.Key = key
+		if hasValue {
+			toleration.Operator = corev1.TolerationOpEqual
+			toleration.Value = tolerationValue
+		}
+		tolerations = append(tolerations, toleration)
+	}
+	return tolerations, nil
+}
+
+// ParseResources parses resources given as name=quantity, e.g. cpu=500m,memory=512Mi.
+func ParseResources(values map[string]string) (corev1.ResourceList, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	resources := corev1.ResourceList{}
+	for name, value := range values {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantity %q for %s: %w", value, name, err)
+		}
+		resources[corev1.ResourceName(name)] = quantity
+	}
+	return resources, nil
+}
@@ -0,0 +1,119 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+)
+
+// BackupCandidate is a backup found in the listings of the components, with the state of each part. The backup ID
+// is the Unix time the backup was started at.
+type BackupCandidate struct {
+	ID    int64
+	Parts map[string]component.State
+}
+
+func (c BackupCandidate) Time() time.Time {
+	return time.Unix(c.ID, 0)
+}
+
+// Complete reports whether every listed component has a completed part of the backup.
+func (c BackupCandidate) Complete(parts []string) bool {
+	for _, part := range parts {
+		if c.Parts[part] != component.StateCompleted {
+			return false
+		}
+	}
+	return true
+}
+
+// BackupSelection is the result of SelectBackup: all candidates, newest first, and the chosen one, if any.
+type BackupSelection struct {
+	Parts      []string
+	Candidates []BackupCandidate
+	Chosen     *BackupCandidate
+}
+
+func (s BackupSelection) Print(w io.Writer) {
+	fmt.Fprintf(w, "Backups (%s):\n", strings.Join(s.Parts, ", "))
+	for _, candidate := range s.Candidates {
+		var states []string
+		for _, part := range s.Parts {
+			state := candidate.Parts[part]
+			if state == "" {
+				state = "MISSING"
+			}
+			states = append(states, string(state))
+		}
+		marker := " "
+		if s.Chosen != nil && s.Chosen.ID == candidate.ID {
+			marker = "*"
+		}
+		fmt.Fprintf(w, "%s %d  %s  %s\n", marker, candidate.ID, candidate.Time().UTC().Format(time.RFC3339), strings.Join(states, ", "))
+	}
+}
+
+// SelectBackup lists the backups of the components of the definition that have an endpoint and chooses the newest
+// backup started before the given time, or the newest at all if it is zero, that is COMPLETED in every component.
+func SelectBackup(ctx context.Context, definition RestoreDefinition, before time.Time) (*BackupSelection, error) {
+	components := configuredComponents(definition)
+	candidates := map[int64]*BackupCandidate{}
+	record := func(part string, id int64, state component.State) {
+		if candidates[id] == nil {
+			candidates[id] = &BackupCandidate{ID: id, Parts: map[string]component.State{}}
+		}
+		candidates[id].Parts[part] = state
+	}
+	selection := &BackupSelection{}
+
+	for _, c := range backupClients(definition, components) {
+		backups, err := c.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		selection.Parts = append(selection.Parts, c.Name())
+		for _, backup := range backups {
+			record(c.Name(), backup.ID, backup.State)
+		}
+	}
+	if len(selection.Parts) == 0 {
+		return nil, fmt.Errorf("no component endpoint given to list the backups of")
+	}
+
+	for _, candidate := range candidates {
+		selection.Candidates = append(selection.Candidates, *candidate)
+	}
+	sort.Slice(selection.Candidates, func(i, j int) bool {
+		return selection.Candidates[i].ID > selection.Candidates[j].ID
+	})
+	for i, candidate := range selection.Candidates {
+		if !before.IsZero() && !candidate.Time().Before(before) {
+			continue
+		}
+		if candidate.Complete(selection.Parts) {
+			selection.Chosen = &selection.Candidates[i]
+			break
+		}
+	}
+	return selection, nil
+}
+
+// ParseTime parses the --before time: RFC 3339 with or without seconds, or a local date and time.
+func ParseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %q, use e.g. 2006-01-02T15:04Z", value)
+}
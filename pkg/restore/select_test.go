@@ -0,0 +1,36 @@
+package restore
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "rfc 3339", value: "2023-05-04T10:20:30Z", want: time.Date(2023, 5, 4, 10, 20, 30, 0, time.UTC)},
+		{name: "rfc 3339 with offset", value: "2023-05-04T10:20:30+02:00", want: time.Date(2023, 5, 4, 8, 20, 30, 0, time.UTC)},
+		{name: "without seconds", value: "2023-05-04T10:20Z", want: time.Date(2023, 5, 4, 10, 20, 0, 0, time.UTC)},
+		{name: "local with seconds", value: "2023-05-04T10:20:30", want: time.Date(2023, 5, 4, 10, 20, 30, 0, time.Local)},
+		{name: "local", value: "2023-05-04T10:20", want: time.Date(2023, 5, 4, 10, 20, 0, 0, time.Local)},
+		{name: "local with a space", value: "2023-05-04 10:20", want: time.Date(2023, 5, 4, 10, 20, 0, 0, time.Local)},
+		{name: "date only", value: "2023-05-04", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "not a time", value: "yesterday", wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseTime(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseTime(%q) error = %v, wantErr %v", test.value, err, test.wantErr)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("ParseTime(%q) = %s, want %s", test.value, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	apps "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const zeebeDataPath = "/usr/local/zeebe/data"
+
+// ZeebeTopology is the layout of the Zeebe brokers as configured in their StatefulSet.
+type ZeebeTopology struct {
+	StatefulSet       string   `json:"statefulSet"`
+	Claim             string   `json:"claim"`
+	ClusterSize       int      `json:"clusterSize"`
+	PartitionsCount   int      `json:"partitionsCount"`
+	ReplicationFactor int      `json:"replicationFactor"`
+	Brokers           []Broker `json:"brokers"`
+}
+
+// Broker is the data PVC of one broker. The node ID is the StatefulSet ordinal. Create is set for PVCs that
+// don't exist yet in a fresh installation.
+type Broker struct {
+	NodeID int    `json:"nodeId"`
+	PVC    string `json:"pvc"`
+	Create bool   `json:"create,omitempty"`
+}
+
+// findZeebeStatefulSet returns the StatefulSet with the given name, or the one that looks like Zeebe brokers:
+// labeled as zeebe-broker by the Helm chart, configuring a ZEEBE_BROKER_CLUSTER_CLUSTERSIZE or named zeebe.
+func findZeebeStatefulSet(ctx context.Context, kubeClient kubernetes.Interface, namespace, name string) (*apps.StatefulSet, error) {
+	if name != "" {
+		return kubeClient.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	statefulsets, err := kubeClient.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var candidates []apps.StatefulSet
+	for _, sts := range statefulsets.Items {
+		if sts.Name == "zeebe" || sts.Labels["app.kubernetes.io/component"] == "zeebe-broker" ||
+			(len(sts.Spec.Template.Spec.Containers) > 0 && envValue(sts.Spec.Template.Spec.Containers[0], "ZEEBE_BROKER_CLUSTER_CLUSTERSIZE") != "") {
+			candidates = append(candidates, sts)
+		}
+	}
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("no zeebe statefulset found in namespace %s, pass --zeebe-statefulset", namespace)
+	case 1:
+		return &candidates[0], nil
+	default:
+		var names []string
+		for _, sts := range candidates {
+			names = append(names, sts.Name)
+		}
+		return nil, fmt.Errorf("found several zeebe statefulsets %v in namespace %s, pass --zeebe-statefulset", names, namespace)
+	}
+}
+
+// resolveZeebeTopology maps the data PVCs to broker node IDs by the <claim>-<statefulset>-<ordinal> naming of
+// volumeClaimTemplates, and checks them against the cluster configuration in the env. If fresh is set, missing
+// PVCs are marked to be created instead of failing.
+func resolveZeebeTopology(ctx context.Context, kubeClient *kubernetes.Clientset, sts *apps.StatefulSet, fresh bool) (*ZeebeTopology, error) {
+	container := sts.Spec.Template.Spec.Containers[0]
+	topology := &ZeebeTopology{
+		StatefulSet:       sts.Name,
+		ClusterSize:       envInt(container, "ZEEBE_BROKER_CLUSTER_CLUSTERSIZE", 1),
+		PartitionsCount:   envInt(container, "ZEEBE_BROKER_CLUSTER_PARTITIONSCOUNT", 1),
+		ReplicationFactor: envInt(container, "ZEEBE_BROKER_CLUSTER_REPLICATIONFACTOR", 1),
+	}
+
+	// Zero replicas is fine, e.g. after an aborted restore
+	if sts.Spec.Replicas != nil && *sts.Spec.Replicas != 0 && int(*sts.Spec.Replicas) != topology.ClusterSize {
+		return nil, fmt.Errorf("statefulset %s has %d replicas but a cluster size of %d", sts.Name, *sts.Spec.Replicas, topology.ClusterSize)
+	}
+	if topology.ReplicationFactor > topology.ClusterSize {
+		return nil, fmt.Errorf("statefulset %s has a replication factor of %d but a cluster size of %d", sts.Name, topology.ReplicationFactor, topology.ClusterSize)
+	}
+
+	claim, err := dataClaimTemplate(sts)
+	if err != nil {
+		return nil, err
+	}
+	topology.Claim = claim.Name
+	for ordinal := 0; ordinal < topology.ClusterSize; ordinal++ {
+		pvcName := fmt.Sprintf("%s-%s-%d", claim.Name, sts.Name, ordinal)
+		broker := Broker{NodeID: ordinal, PVC: pvcName}
+		_, err := kubeClient.CoreV1().PersistentVolumeClaims(sts.Namespace).Get(ctx, pvcName, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err) && fresh:
+			broker.Create = true
+		case apierrors.IsNotFound(err):
+			return nil, fmt.Errorf("pvc %s of broker %d does not exist, pass --fresh to create it", pvcName, ordinal)
+		case err != nil:
+			return nil, err
+		}
+		topology.Brokers = append(topology.Brokers, broker)
+	}
+	return topology, nil
+}
+
+// dataClaimTemplate returns the volumeClaimTemplate mounted at the Zeebe data directory.
+func dataClaimTemplate(sts *apps.StatefulSet) (*corev1.PersistentVolumeClaim, error) {
+	templates := sts.Spec.VolumeClaimTemplates
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("statefulset %s has no volumeClaimTemplates", sts.Name)
+	}
+	for _, mount := range sts.Spec.Template.Spec.Containers[0].VolumeMounts {
+		if mount.MountPath != zeebeDataPath {
+			continue
+		}
+		for i, template := range templates {
+			if template.Name == mount.Name {
+				return &templates[i], nil
+			}
+		}
+	}
+	if len(templates) == 1 {
+		return &templates[0], nil
+	}
+	return nil, fmt.Errorf("none of the volumeClaimTemplates of statefulset %s is mounted at %s", sts.Name, zeebeDataPath)
+}
+
+// zeebeStatefulSet returns the broker StatefulSet of the target namespace. In a fresh installation without one,
+// the StatefulSet of the source installation is used as the template for the PVCs and the restore Jobs, and
+// inTarget is false.
+func zeebeStatefulSet(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition, namespace string) (sts *apps.StatefulSet, inTarget bool, err error) {
+	sts, err = findZeebeStatefulSet(ctx, kubeClient, namespace, definition.zeebeStatefulSet)
+	if err == nil {
+		return sts, true, nil
+	}
+	if !definition.fresh || definition.sourceKubeClient == nil {
+		return nil, false, err
+	}
+	fmt.Printf("no zeebe statefulset in namespace %s (%v), using the one of namespace %s as template\n", namespace, err, definition.sourceNamespace)
+	sts, err = findZeebeStatefulSet(ctx, definition.sourceKubeClient, definition.sourceNamespace, definition.zeebeStatefulSet)
+	if err != nil {
+		return nil, false, err
+	}
+	sts = sts.DeepCopy()
+	sts.Namespace = namespace
+	return sts, false, nil
+}
+
+// ZeebeClusterSize returns the cluster size the Zeebe StatefulSet of the namespace is configured with. An empty
+// statefulSet is detected.
+func ZeebeClusterSize(ctx context.Context, kubeClient kubernetes.Interface, namespace, statefulSet string) (int, error) {
+	sts, err := findZeebeStatefulSet(ctx, kubeClient, namespace, statefulSet)
+	if err != nil {
+		return 0, err
+	}
+	return envInt(sts.Spec.Template.Spec.Containers[0], "ZEEBE_BROKER_CLUSTER_CLUSTERSIZE", 1), nil
+}
+
+// checkBackupTopology refuses to restore a backup into a cluster of a different shape. A backupClusterSize of 0
+// means it was not recorded.
+func checkBackupTopology(topology *ZeebeTopology, backupPartitions, backupClusterSize int) error {
+	if backupPartitions != topology.PartitionsCount {
+		return fmt.Errorf("backup has %d partitions but statefulset %s is configured with %d", backupPartitions, topology.StatefulSet, topology.PartitionsCount)
+	}
+	if backupClusterSize != 0 && backupClusterSize != topology.ClusterSize {
+		return fmt.Errorf("backup was taken with a cluster size of %d but statefulset %s has %d", backupClusterSize, topology.StatefulSet, topology.ClusterSize)
+	}
+	return nil
+}
+
+func envInt(container corev1.Container, name string, defaultValue int) int {
+	value, err := strconv.Atoi(envValue(container, name))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
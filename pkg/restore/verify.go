@@ -0,0 +1,331 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/backup-client/webapps"
+	apps "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// VerifyReport is the outcome of a verify-restore run. It passes if every check passed.
+type VerifyReport struct {
+	BackupID  int64
+	Namespace string
+	Checks    []VerifyCheck
+	Warnings  []string
+}
+
+// VerifyCheck is one check of a verify-restore run, Err is nil if it passed.
+type VerifyCheck struct {
+	Name string
+	Err  error
+}
+
+func (r *VerifyReport) check(name string, err error) bool {
+	r.Checks = append(r.Checks, VerifyCheck{Name: name, Err: err})
+	return err == nil
+}
+
+func (r *VerifyReport) Passed() bool {
+	if len(r.Checks) == 0 {
+		return false
+	}
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *VerifyReport) Print(w io.Writer) {
+	fmt.Fprintf(w, "Verification of backup %d in namespace %s\n", r.BackupID, r.Namespace)
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "WARNING: %s\n", warning)
+	}
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			fmt.Fprintf(w, "  FAIL %s: %v\n", check.Name, check.Err)
+		} else {
+			fmt.Fprintf(w, "  PASS %s\n", check.Name)
+		}
+	}
+	if r.Passed() {
+		fmt.Fprintln(w, "Result: PASS")
+	} else {
+		fmt.Fprintln(w, "Result: FAIL")
+	}
+}
+
+// verifyRun is the state of one verify-restore run.
+type verifyRun struct {
+	kubeClient    *kubernetes.Clientset
+	elasticClient *elastic.Client
+	definition    RestoreDefinition
+	indexPrefix   string
+	report        *VerifyReport
+	// ownsIndices is set once the prefix was found unused, only then teardown deletes the indices with the prefix
+	ownsIndices bool
+}
+
+// VerifyRestore restores the backup into the scratch namespace of the definition without touching the source
+// installation: the Elasticsearch snapshots under renamed indices and the Zeebe data into new PVCs of a clone of
+// the broker StatefulSet. It boots the brokers, runs health checks and tears everything down again.
+func VerifyRestore(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition) *VerifyReport {
+	if definition.sourceKubeClient == nil {
+		definition.sourceKubeClient = kubeClient
+	}
+	if definition.sourceKubeClient == kubeClient && definition.sourceNamespace == definition.namespace {
+		report := &VerifyReport{BackupID: definition.backupID, Namespace: definition.namespace}
+		report.check("scratch namespace", fmt.Errorf("the scratch namespace %s is the namespace of the backed up installation, pass another --namespace", definition.namespace))
+		return report
+	}
+	indexPrefix := definition.renamePrefix
+	if indexPrefix == "" {
+		indexPrefix = fmt.Sprintf("verify-%d-", definition.backupID)
+	}
+
+	run := &verifyRun{
+		kubeClient:    kubeClient,
+		elasticClient: elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName),
+		definition:    definition,
+		indexPrefix:   indexPrefix,
+		report:        &VerifyReport{BackupID: definition.backupID, Namespace: definition.namespace},
+	}
+	if !definition.keep {
+		defer run.teardown()
+	}
+
+	if run.restoreSnapshots(ctx) {
+		run.checkProcessInstances(ctx)
+	}
+	if run.bootZeebe(ctx) {
+		run.checkOperate(ctx)
+	}
+	return run.report
+}
+
+func (v *verifyRun) restoreSnapshots(ctx context.Context) bool {
+	snapshots, err := gatherSnapshotNames(ctx, v.definition.backupID, backupClients(v.definition, configuredComponents(v.definition)))
+	if err == nil && len(snapshots) == 0 {
+		err = fmt.Errorf("no snapshots found for backup %d", v.definition.backupID)
+	}
+	if !v.report.check("snapshots of the backup found", err) {
+		return false
+	}
+
+	leftovers, err := v.verifyIndices(ctx)
+	if err == nil && len(leftovers) > 0 {
+		err = fmt.Errorf("indices %v of an earlier verification exist, delete them or pass another --rename-prefix", leftovers)
+	}
+	if !v.report.check("no indices with prefix "+v.indexPrefix, err) {
+		return false
+	}
+	v.ownsIndices = true
+
+	passed := true
+	for _, snapshot := range snapshots {
+		fmt.Println("restoring", snapshot, "renamed with prefix", v.indexPrefix)
+		err := v.elasticClient.RestoreSnapshotRenamed(ctx, snapshot, "(.+)", v.indexPrefix+"$1")
+		passed = v.report.check("restore snapshot "+snapshot, err) && passed
+	}
+	return passed
+}
+
+// checkProcessInstances compares the process instances of the restored Operate list view with the live one. The
+// live installation kept running since the backup, so it may have more, but not none restored of many.
+func (v *verifyRun) checkProcessInstances(ctx context.Context) {
+	indices, err := v.verifyIndices(ctx)
+	if !v.report.check("list restored indices", err) {
+		return
+	}
+	listView := v.indexPrefix + indexPrefixes(v.definition.indexPrefixes)[webapps.OperateApp] + "list-view"
+	for _, index := range indices {
+		if !strings.HasPrefix(index, listView) {
+			continue
+		}
+		const query = "joinRelation:processInstance"
+		restored, err := v.elasticClient.CountDocuments(ctx, index, query)
+		if !v.report.check("count process instances of "+index, err) {
+			return
+		}
+		live, err := v.elasticClient.CountDocuments(ctx, strings.TrimPrefix(index, v.indexPrefix), query)
+		if err != nil {
+			v.report.Warnings = append(v.report.Warnings, fmt.Sprintf("unable to count the live process instances: %v", err))
+			live = 0
+		}
+		if restored == 0 && live > 0 {
+			err = fmt.Errorf("no process instances restored, %d live", live)
+		}
+		v.report.check(fmt.Sprintf("process instance count plausible (%d restored, %d live)", restored, live), err)
+		return
+	}
+	v.report.Warnings = append(v.report.Warnings, "no operate list view index restored, process instances not counted")
+}
+
+// bootZeebe restores the Zeebe data into new PVCs in the scratch namespace and starts a clone of the brokers on them.
+func (v *verifyRun) bootZeebe(ctx context.Context) bool {
+	definition := v.definition
+	namespace := definition.namespace
+	timeout := definition.jobTimeout
+	if timeout == 0 {
+		timeout = DefaultJobTimeout
+	}
+
+	source, err := findZeebeStatefulSet(ctx, definition.sourceKubeClient, definition.sourceNamespace, definition.zeebeStatefulSet)
+	if !v.report.check("find zeebe statefulset", err) {
+		return false
+	}
+	_, err = v.kubeClient.AppsV1().StatefulSets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	if err == nil {
+		err = fmt.Errorf("statefulset %s exists in namespace %s, verify needs a namespace without zeebe", source.Name, namespace)
+	} else if apierrors.IsNotFound(err) {
+		err = nil
+	}
+	if !v.report.check("no zeebe in namespace "+namespace, err) {
+		return false
+	}
+
+	clone, warnings := cloneStatefulSet(source, namespace, 0, v.indexPrefix, definition.backupID)
+	v.report.Warnings = append(v.report.Warnings, warnings...)
+	topology, err := resolveZeebeTopology(ctx, v.kubeClient, clone, true)
+	if err == nil {
+		for _, broker := range topology.Brokers {
+			if !broker.Create {
+				err = fmt.Errorf("pvc %s exists in namespace %s, delete it first", broker.PVC, namespace)
+				break
+			}
+		}
+	}
+	if !v.report.check("zeebe topology", err) {
+		return false
+	}
+
+	// The restore Jobs run with the env of the brokers and the backup store applied
+	store, err := v.backupStore(source)
+	if err == nil {
+		spec := clone.Spec.Template.Spec.DeepCopy()
+		store.Apply(spec)
+		err = copyReferences(ctx, definition.sourceKubeClient, v.kubeClient, definition.sourceNamespace, namespace, referencesOf(*spec), definition.backupID)
+	}
+	if !v.report.check("copy configmaps, secrets and serviceaccount", err) {
+		return false
+	}
+
+	err = createBrokerPVCs(ctx, v.kubeClient, clone, topology, timeout)
+	if !v.report.check("create zeebe pvcs", err) {
+		return false
+	}
+	if !v.report.check("restore zeebe data", v.restoreZeebeData(ctx, source, store, topology, timeout)) {
+		return false
+	}
+
+	err = v.createService(ctx, source)
+	if err == nil {
+		replicas := int32(topology.ClusterSize)
+		clone.Spec.Replicas = &replicas
+		_, err = v.kubeClient.AppsV1().StatefulSets(namespace).Create(ctx, clone, metav1.CreateOptions{FieldManager: "c8-backup"})
+	}
+	if !v.report.check("start zeebe brokers", err) {
+		return false
+	}
+
+	err = waitHealthy(ctx, "zeebe", timeout, func(ctx context.Context) error {
+		err := statefulSetReady(ctx, v.kubeClient, namespace, clone.Name)
+		if err != nil {
+			return err
+		}
+		return zeebeTopologyHealthy(ctx, v.kubeClient, clone, topology)
+	})
+	return v.report.check("zeebe topology healthy", err)
+}
+
+// backupStore is the store given for the restore, or else the one the source brokers are configured with.
+func (v *verifyRun) backupStore(source *apps.StatefulSet) (BackupStore, error) {
+	if v.definition.backupStore != nil {
+		return v.definition.backupStore, nil
+	}
+	return DetectBackupStore(source)
+}
+
+func (v *verifyRun) restoreZeebeData(ctx context.Context, source *apps.StatefulSet, store BackupStore, topology *ZeebeTopology, timeout time.Duration) error {
+	// The restore Jobs start from the source brokers, they read the backup store like them
+	template := source.DeepCopy()
+	template.Namespace = v.definition.namespace
+
+	var jobs []*batchv1.Job
+	for _, broker := range topology.Brokers {
+		job := NewRestoreJob(broker.PVC, broker.NodeID, template, v.definition.backupID, store, v.definition.jobOverrides)
+		err := pinToVolume(ctx, v.kubeClient, &job.Spec.Template.Spec, template.Namespace, broker.PVC)
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, job)
+	}
+	return NewJobRunner(v.kubeClient, template.Namespace, timeout).Run(ctx, "job=restore-zeebe", jobs)
+}
+
+func (v *verifyRun) createService(ctx context.Context, source *apps.StatefulSet) error {
+	if source.Spec.ServiceName == "" {
+		return nil
+	}
+	service, err := v.definition.sourceKubeClient.CoreV1().Services(source.Namespace).Get(ctx, source.Spec.ServiceName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	_, err = v.kubeClient.CoreV1().Services(v.definition.namespace).Create(ctx, cloneService(service, v.definition.namespace, v.definition.backupID), metav1.CreateOptions{FieldManager: "c8-backup"})
+	return err
+}
+
+// checkOperate waits for an Operate installed in the scratch namespace, if its mgmt endpoint is given.
+func (v *verifyRun) checkOperate(ctx context.Context) {
+	if v.definition.verifyOperateURL == "" {
+		return
+	}
+	err := waitHealthy(ctx, "operate", DefaultHealthTimeout, func(ctx context.Context) error {
+		return webappHealthy(ctx, v.definition.verifyOperateURL)
+	})
+	v.report.check("operate reachable", err)
+}
+
+// verifyIndices returns the existing indices with the rename prefix of the run.
+func (v *verifyRun) verifyIndices(ctx context.Context) ([]string, error) {
+	existing, err := v.elasticClient.ListIndices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var indices []string
+	for _, index := range existing {
+		if strings.HasPrefix(index, v.indexPrefix) {
+			indices = append(indices, index)
+		}
+	}
+	return indices, nil
+}
+
+// teardown removes the clone and the renamed indices. Its failures fail the report, leftovers cost money.
+func (v *verifyRun) teardown() {
+	ctx := context.Background()
+	fmt.Println("tearing down the verification in namespace", v.definition.namespace)
+	errorList := deleteClone(ctx, v.kubeClient, v.definition.namespace, v.definition.backupID)
+	if v.ownsIndices {
+		indices, err := v.verifyIndices(ctx)
+		if err == nil {
+			err = v.elasticClient.DeleteIndices(ctx, indices)
+		}
+		if err != nil {
+			errorList = append(errorList, err)
+		}
+	}
+	v.report.check("teardown", errors.Join(errorList...))
+}
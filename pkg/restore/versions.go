@@ -0,0 +1,91 @@
+package restore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"c8backup/pkg/backup-client/webapps"
+	"c8backup/pkg/backup-client/zeebe"
+	"c8backup/pkg/compat"
+	"c8backup/pkg/manifest"
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkVersions compares the versions the backup was taken with against the versions running in the target
+// namespace. Incompatible versions fail the plan unless the check is ignored, then they are a warning.
+func checkVersions(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition, plan *Plan) error {
+	backup, err := backupVersions(ctx, definition, plan)
+	if err != nil {
+		return err
+	}
+	plan.Versions = backup
+
+	warnings, err := compat.CheckRestore(backup, runningVersions(ctx, kubeClient, definition))
+	plan.Warnings = append(plan.Warnings, warnings...)
+	if err == nil {
+		return nil
+	}
+	if definition.ignoreVersionCheck {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("version check ignored: %v", err))
+		return nil
+	}
+	return fmt.Errorf("%w, pass --ignore-version-check to restore anyway", err)
+}
+
+// backupVersions reads the versions from the manifest of the backup, also from an unsigned one. Backups without one
+// only tell the version of the brokers.
+func backupVersions(ctx context.Context, definition RestoreDefinition, plan *Plan) (map[string]string, error) {
+	if plan.Manifest != nil {
+		return plan.Manifest.Versions, nil
+	}
+	if definition.sourceKubeClient != nil && !definition.ignoreManifest {
+		backupManifest, err := manifest.Read(ctx, definition.sourceKubeClient, definition.sourceNamespace, definition.backupID)
+		if err != nil {
+			return nil, err
+		}
+		if backupManifest != nil {
+			return backupManifest.Versions, nil
+		}
+	}
+	versions := map[string]string{}
+	if definition.zeebeURL != "" {
+		backup, err := zeebeBackup.NewZeebeClient(definition.zeebeURL).GetBackup(ctx, definition.backupID)
+		if err == nil && backup != nil && len(backup.Details) > 0 {
+			versions[ComponentZeebe] = backup.Details[0].BrokerVersion
+		}
+	}
+	return versions, nil
+}
+
+// runningVersions are the versions of the webapps that answer and of the Zeebe image. Unknown ones are skipped.
+func runningVersions(ctx context.Context, kubeClient *kubernetes.Clientset, definition RestoreDefinition) map[string]string {
+	versions := map[string]string{}
+	for _, app := range []string{webapps.OperateApp, webapps.TasklistApp, webapps.OptimizeApp} {
+		if definition.endpoint(app) == "" {
+			continue
+		}
+		client, _ := webapps.NewBackupClient(app, definition.endpoint(app))
+		version, err := client.Version(ctx)
+		if err != nil {
+			fmt.Printf("unable to get the version of %s: %v\n", app, err)
+			continue
+		}
+		versions[app] = version
+	}
+	sts, _, err := zeebeStatefulSet(ctx, kubeClient, definition, definition.namespace)
+	if err == nil {
+		versions[ComponentZeebe] = imageTag(sts.Spec.Template.Spec.Containers[0].Image)
+	}
+	return versions
+}
+
+// imageTag returns the tag of an image like camunda/zeebe:8.2.5, empty if it has none.
+func imageTag(image string) string {
+	image, _, _ = strings.Cut(image, "@")
+	i := strings.LastIndex(image, ":")
+	if i < 0 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
@@ -2,6 +2,7 @@ package restore
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	apps "k8s.io/api/apps/v1"
@@ -10,27 +11,42 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-func NewDeletionJob(pvcName, pvcNamespace string) *v1.Job {
-	return &v1.Job{
+func deletionJobName(pvcName string) string {
+	return "delete-" + pvcName
+}
+
+func restoreJobName(pvcName string) string {
+	return "restore-" + pvcName
+}
+
+// DefaultHelperImage wipes the Zeebe PVCs. It is pinned, so admission policies forbidding :latest accept it.
+const DefaultHelperImage = "busybox:1.36.1"
+
+// jobBackoffLimit is how often a failing delete or restore pod is retried before the Job fails.
+var jobBackoffLimit = int32(2)
+
+func NewDeletionJob(pvcName string, sts *apps.StatefulSet, overrides JobOverrides) *v1.Job {
+	job := &v1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "delete-" + pvcName,
-			Namespace: pvcNamespace,
+			Name:      deletionJobName(pvcName),
+			Namespace: sts.Namespace,
 			Labels: map[string]string{
 				"job": "delete-zeebe",
 			},
 		},
 		Spec: v1.JobSpec{
+			BackoffLimit: &jobBackoffLimit,
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
 					Containers: []corev1.Container{
 						{
 							Name:  "delete-" + pvcName,
-							Image: "busybox:latest",
+							Image: helperImage(sts, overrides),
 							Command: []string{
 								"/bin/sh",
 								"-c",
-								"rm -rf /usr/local/zeebe/data/*",
+								"find /usr/local/zeebe/data -mindepth 1 -delete",
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
@@ -54,33 +70,58 @@ func NewDeletionJob(pvcName, pvcNamespace string) *v1.Job {
 			},
 		},
 	}
+
+	inheritPodTemplate(&job.Spec.Template.Spec, sts, overrides)
+	return job
+}
+
+// helperImage is the image that wipes the PVCs: the Zeebe image itself if asked for, otherwise the helper image
+// pulled through the registry mirror.
+func helperImage(sts *apps.StatefulSet, overrides JobOverrides) string {
+	if overrides.WipeWithZeebeImage {
+		return sts.Spec.Template.Spec.Containers[0].Image
+	}
+	image := overrides.HelperImage
+	if image == "" {
+		image = DefaultHelperImage
+	}
+	return mirrorImage(image, overrides.ImageRegistry)
+}
+
+// mirrorImage replaces the registry of the image with the mirror, e.g. busybox:1.36.1 becomes
+// registry.local/mirror/busybox:1.36.1.
+func mirrorImage(image, registry string) string {
+	if registry == "" {
+		return image
+	}
+	if host, rest, found := strings.Cut(image, "/"); found && (strings.ContainsAny(host, ".:") || host == "localhost") {
+		image = rest
+	}
+	return strings.TrimSuffix(registry, "/") + "/" + image
 }
 
-func NewRestoreJob(pvc corev1.PersistentVolumeClaim, sts *apps.StatefulSet, backupID int64) *v1.Job {
+func NewRestoreJob(pvcName string, nodeID int, sts *apps.StatefulSet, backupID int64, store BackupStore, overrides JobOverrides) *v1.Job {
 	container := sts.Spec.Template.Spec.Containers[0].DeepCopy()
 	zeebeImage := container.Image
+	setEnv(container, "ZEEBE_BROKER_CLUSTER_NODEID", strconv.Itoa(nodeID))
 	env := container.Env
-	nodeID := strings.Split(pvc.Name, "-")[2]
-	env = append(env, corev1.EnvVar{
-		Name:  "ZEEBE_BROKER_CLUSTER_NODEID",
-		Value: nodeID,
-	})
 
-	return &v1.Job{
+	job := &v1.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "restore-" + pvc.Name,
-			Namespace: pvc.Namespace,
+			Name:      restoreJobName(pvcName),
+			Namespace: sts.Namespace,
 			Labels: map[string]string{
 				"job": "restore-zeebe",
 			},
 		},
 		Spec: v1.JobSpec{
+			BackoffLimit: &jobBackoffLimit,
 			Template: corev1.PodTemplateSpec{
 				Spec: corev1.PodSpec{
 					RestartPolicy: corev1.RestartPolicyNever,
 					Containers: []corev1.Container{
 						{
-							Name:  "restore-" + pvc.Name,
+							Name:  restoreJobName(pvcName),
 							Image: zeebeImage,
 							Command: []string{
 								"/usr/local/zeebe/bin/restore",
@@ -92,11 +133,6 @@ func NewRestoreJob(pvc corev1.PersistentVolumeClaim, sts *apps.StatefulSet, back
 									Name:      "data",
 									MountPath: "/usr/local/zeebe/data",
 								},
-								{
-									Name:      "gcs-backup-key",
-									MountPath: "usr/local/share/gcs-key",
-									ReadOnly:  true,
-								},
 							},
 						},
 					},
@@ -105,15 +141,7 @@ func NewRestoreJob(pvc corev1.PersistentVolumeClaim, sts *apps.StatefulSet, back
 							Name: "data",
 							VolumeSource: corev1.VolumeSource{
 								PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-									ClaimName: pvc.Name,
-								},
-							},
-						},
-						{
-							Name: "gcs-backup-key",
-							VolumeSource: corev1.VolumeSource{
-								Secret: &corev1.SecretVolumeSource{
-									SecretName: "gcs-backup-secret",
+									ClaimName: pvcName,
 								},
 							},
 						},
@@ -122,4 +150,8 @@ func NewRestoreJob(pvc corev1.PersistentVolumeClaim, sts *apps.StatefulSet, back
 			},
 		},
 	}
+
+	inheritPodTemplate(&job.Spec.Template.Spec, sts, overrides)
+	store.Apply(&job.Spec.Template.Spec)
+	return job
 }
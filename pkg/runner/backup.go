@@ -2,208 +2,304 @@ package runner
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
+	"c8backup/pkg/backup-client/component"
 	"c8backup/pkg/backup-client/elastic"
 	"c8backup/pkg/backup-client/webapps"
 	"c8backup/pkg/backup-client/zeebe"
+	"c8backup/pkg/manifest"
+	"k8s.io/client-go/kubernetes"
 )
 
 var backupID int64
 
+// record is the manifest of the running backup, each component adds its outcome
+var record *manifest.Manifest
+
 type BackupDefinition struct {
 	elasticURL           string
 	operateURL           string
 	tasklistURL          string
 	optimizeURL          string
 	zeebeURL             string
+	zeebeStatefulSet     string
 	zeebeIndexPrefix     string
 	backupID             int64
 	backupRepositoryName string
+	components           []component.Component
+	kubeClient           kubernetes.Interface
+	namespace            string
+	ignoreVersionCheck   bool
+	maxExportPause       time.Duration
+	hardPause            bool
 }
 
 const timeout = time.Minute
 const pollInterval = time.Second * 5
 
-func DoBackup(definition BackupDefinition) {
-	ctx := context.Background()
+func DoBackup(ctx context.Context, definition BackupDefinition) error {
 	backupID = definition.backupID
+	record = manifest.New(backupID)
+	// The snapshot of the Zeebe records is taken while exporting is paused, so it needs Zeebe
+	if definition.elasticURL != "" && definition.zeebeURL == "" {
+		return errors.New("the elasticsearch snapshot of the zeebe records needs --zeebe")
+	}
 
-	// Operate
-	if definition.operateURL != "" {
-		operate, _ := webapps.NewBackupClient("operate", definition.operateURL)
-		err := operate.RequestBackup(ctx, backupID)
-		if err != nil {
-			log.Println("operate backup request failed")
-			log.Fatal(err)
+	// The versions are checked before exporting is paused, the brokers' again once their backup tells it
+	record.Versions = probeVersions(ctx, definition)
+	err := checkVersions(definition, record.Versions)
+	if err != nil {
+		return err
+	}
+
+	err = backupComponents(ctx, definition)
+	// An interrupted backup cancelled ctx, what was backed up is recorded anyway
+	if definition.zeebeURL != "" {
+		zeebe := zeebeBackup.NewZeebeClient(definition.zeebeURL)
+		if version := recordZeebe(context.Background(), definition, zeebe); version != "" {
+			record.Versions[zeebe.Name()] = version
 		}
-		operateBackup := pollUntilBackupCompleted(ctx, operate)
-		handleResponse(operateBackup, operate.Name())
 	}
-	// Optimize
-	if definition.optimizeURL != "" {
-		optimize, _ := webapps.NewBackupClient("optimize", definition.optimizeURL)
-		err := optimize.RequestBackup(ctx, backupID)
-		if err != nil {
-			log.Println("optimize backup request failed")
-			log.Fatal(err)
+	writeManifest(context.Background(), definition)
+	if err != nil {
+		return fmt.Errorf("❌ backup %d failed: %w", backupID, err)
+	}
+	err = checkVersions(definition, record.Versions)
+	if err != nil {
+		return err
+	}
+	var unfinished []string
+	for _, entry := range record.Components {
+		if entry.State != component.StateCompleted {
+			unfinished = append(unfinished, fmt.Sprintf("%s is %s", entry.Name, entry.State))
 		}
-		optimizeBackup := pollUntilBackupCompleted(ctx, optimize)
-		handleResponse(optimizeBackup, optimize.Name())
 	}
-	// Tasklist
-	if definition.tasklistURL != "" {
-		tasklist, _ := webapps.NewBackupClient("tasklist", definition.tasklistURL)
-		err := tasklist.RequestBackup(ctx, backupID)
+	if len(unfinished) > 0 {
+		return fmt.Errorf("❌ backup %d failed: %s", backupID, strings.Join(unfinished, ", "))
+	}
+	log.Println("🚀🚀🚀backup DONE!🚀🚀🚀")
+	log.Println("BackupID: ", backupID)
+	return nil
+}
+
+// backupComponents backs up the webapps, then Zeebe and then the registered components. A failed Zeebe backup
+// doesn't keep the registered components from being backed up, an interrupted backup stops right away.
+func backupComponents(ctx context.Context, definition BackupDefinition) error {
+	for _, app := range definition.webapps() {
+		_, err := takeBackup(ctx, app, timeout)
 		if err != nil {
-			log.Println("tasklist backup request failed")
-			log.Fatal(err)
+			return fmt.Errorf("%s backup: %w", app.Name(), err)
 		}
-		tasklistBackup := pollUntilBackupCompleted(ctx, tasklist)
-		handleResponse(tasklistBackup, tasklist.Name())
 	}
 	log.Println("✅ ✅ ✅ WEBAPPS  ✅ ✅ ✅")
 
 	// Once Webapps are finished
+	var zeebeErr error
 	if definition.zeebeURL != "" {
-		zeebe := zeebeBackup.NewZeebeClient(definition.zeebeURL)
-		defer func(zeebe *zeebeBackup.BackupClient, ctx context.Context) {
-			err := zeebe.ResumeExporting(ctx)
-			if err != nil {
-				log.Fatal("Error resuming zeebe export", err)
-			}
-			log.Println("▶️▶️▶️ZEEBE EXPORT RESUMED ▶️▶️▶️")
-		}(zeebe, ctx)
-		// Zeebe Stop Exporting
-		err := zeebe.StopExporting(ctx)
-		if err != nil {
-			fmt.Println("Error stopping zeebe export ", definition.backupID)
-			return
-		}
-		log.Println("⏸️ ⏸️ ⏸️ ️ZEEBE EXPORT STOPPED  ⏸️ ⏸️ ⏸️")
-		err = zeebe.RequestBackup(ctx, backupID)
+		zeebeErr = backupZeebe(ctx, definition)
+	}
+	if ctx.Err() != nil {
+		return errors.Join(zeebeErr, ctx.Err())
+	}
+	for _, c := range definition.components {
+		_, err := takeBackup(ctx, c, timeout)
 		if err != nil {
-			log.Fatal(err)
+			return errors.Join(zeebeErr, fmt.Errorf("%s backup: %w", c.Name(), err))
 		}
-		completedBackup := waitUntilZeebeBackupCompleted(ctx, zeebe)
-		select {
-		case res := <-completedBackup:
-			log.Printf("✅ %s Done! %s \n", "zeebe", res.State)
-			log.Println("✅ ✅ ✅ ZEEBE DONE")
-			return
-		case <-time.After(timeout):
-			log.Printf("%s timed out\n", "zeebe")
-			return
+	}
+	return zeebeErr
+}
+
+// webapps returns the webapps with an endpoint, in the order they are backed up.
+func (d BackupDefinition) webapps() []*webapps.BackupClient {
+	var apps []*webapps.BackupClient
+	for _, app := range []struct{ name, url string }{
+		{webapps.OperateApp, d.operateURL},
+		{webapps.OptimizeApp, d.optimizeURL},
+		{webapps.TasklistApp, d.tasklistURL},
+	} {
+		if app.url != "" {
+			client, _ := webapps.NewBackupClient(app.name, app.url)
+			apps = append(apps, client)
 		}
+	}
+	return apps
+}
 
+// backupZeebe takes the Zeebe backup and, if Elasticsearch is configured, the snapshot of the exported records while
+// exporting is paused. Without Elasticsearch the exporter is disabled and there are no records to snapshot. Both are
+// waited for at most until the maximum pause, then a watchdog resumes exporting and the backup fails. Exporting is
+// resumed as well when ctx is cancelled.
+//
+// A soft pause is preferred unless hardPause is set. Then the exporters keep exporting without moving their positions,
+// so the records are snapshotted right after the pause: a snapshot after the Zeebe backup would hold records past the
+// checkpoints. Zeebe exports the records after its exporter positions again after a restore. With a hard pause
+// nothing is exported meanwhile and the snapshot follows the Zeebe backup.
+func backupZeebe(ctx context.Context, definition BackupDefinition) (err error) {
+	zeebe := zeebeBackup.NewZeebeClient(definition.zeebeURL)
+	// Zeebe Stop Exporting
+	pausedAt := time.Now().UTC()
+	mode, err := zeebe.PauseExporting(ctx, definition.hardPause)
+	if err != nil {
+		// Some partitions may have paused before the request failed
+		resumeErr := zeebe.ResumeExporting(context.Background())
+		if resumeErr != nil {
+			alertPaused(resumeErr)
+		}
+		return fmt.Errorf("unable to pause zeebe exporting: %w", err)
 	}
+	watchdog := startExportWatchdog(zeebe, definition.maxExportPause)
+	defer func() {
+		err = errors.Join(err, watchdog.stop())
+	}()
+	log.Printf("⏸️ ⏸️ ⏸️ ️ZEEBE EXPORT STOPPED (%s pause) ⏸️ ⏸️ ⏸️\n", mode)
+	record.Zeebe = &manifest.Zeebe{PauseMode: string(mode), PausedAt: pausedAt}
+	forced := fmt.Errorf("zeebe exporting was resumed after the maximum pause of %s", definition.maxExportPause)
 
+	var records *elastic.ZeebeRecords
 	if definition.elasticURL != "" {
-		elasticBkp := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
-		_, err := elasticBkp.RequestSnapshot(ctx, backupID, definition.zeebeIndexPrefix)
+		elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
+		records = elastic.NewZeebeRecords(elasticClient, definition.zeebeIndexPrefix)
+	} else {
+		log.Println("no --elastic given, skipping the snapshot of the zeebe records")
+	}
+	// Unless the pause is known to be hard, exporting may go on
+	snapshotFirst := mode != zeebeBackup.PauseHard
+	if records != nil && snapshotFirst {
+		err := backupRecords(ctx, watchdog, records, forced)
 		if err != nil {
-			log.Fatal(err)
-		}
-		completedBackup := pollUntilElasticCompleted(ctx, elasticBkp)
-		select {
-		case res := <-completedBackup:
-			for _, snapshot := range res.Snapshots {
-				log.Printf("Elastic Snapshot in state %s. Name: %s", snapshot.State, snapshot.Snapshot)
-			}
-			log.Println("✅ ✅ ✅ ELASTIC DONE")
-			return
-		case <-time.After(timeout):
-			log.Println("elastic snapshot timed out")
-			return
+			return err
 		}
 	}
-	log.Println("🚀🚀🚀backup DONE!🚀🚀🚀")
-	log.Println("BackupID: ", backupID)
-}
 
-func handleResponse(completedBackup <-chan webapps.BackupResponse, name string) {
-	select {
-	case res := <-completedBackup:
-		log.Printf("✅ %s Done! %s %s\n", name, res.State, res.FailureReason)
-		return
-	case <-time.After(timeout):
-		log.Printf("%s timed out\n", name)
-		return
+	// Failures return instead of exiting, so that exporting is resumed
+	completed, err := takeBackup(ctx, zeebe, watchdog.remaining())
+	if err != nil {
+		return fmt.Errorf("zeebe backup: %w", err)
 	}
-}
+	if watchdog.forcedResume() {
+		failComponent(zeebe.Name(), forced.Error())
+		return forced
+	}
+	if !completed {
+		return notCompleted(zeebe.Name(), definition.maxExportPause)
+	}
+	log.Println("✅ ✅ ✅ ZEEBE DONE")
 
-func pollUntilBackupCompleted(ctx context.Context, client *webapps.BackupClient) <-chan webapps.BackupResponse {
-	completedBackup := make(chan webapps.BackupResponse, 1)
-	go func() {
-		for {
-			backupInfo, err := client.GetBackup(ctx, backupID)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			if backupInfo != nil {
-				log.Printf("%s Backup in state: %s \n", client.Name(), backupInfo.State)
-				if backupInfo.State == "COMPLETED" {
-					completedBackup <- *backupInfo
-					return
-				}
-			}
-			time.Sleep(pollInterval)
-		}
-	}()
+	if records != nil && !snapshotFirst {
+		return backupRecords(ctx, watchdog, records, forced)
+	}
+	return nil
+}
 
-	return completedBackup
+// backupRecords takes the snapshot of the Zeebe records. It fails if the watchdog resumed exporting meanwhile, the
+// snapshot may hold records after the resume then and doesn't fit the Zeebe backup anymore.
+func backupRecords(ctx context.Context, watchdog *exportWatchdog, records *elastic.ZeebeRecords, forced error) error {
+	completed, err := takeBackup(ctx, records, watchdog.remaining())
+	if err != nil {
+		return fmt.Errorf("zeebe records snapshot: %w", err)
+	}
+	if watchdog.forcedResume() {
+		failComponent(records.Name(), forced.Error())
+		return forced
+	}
+	if !completed {
+		return notCompleted(records.Name(), watchdog.maxPause)
+	}
+	log.Println("✅ ✅ ✅ ELASTIC DONE")
+	return nil
 }
 
-func pollUntilElasticCompleted(ctx context.Context, client *elastic.Client) <-chan elastic.SnapshotResponse {
-	completedBackup := make(chan elastic.SnapshotResponse, 1)
-	go func() {
-		for {
-			backupInfo, err := client.GetBackup(ctx, backupID)
-			if err != nil {
-				log.Println(err)
-				continue
-			}
-			if backupInfo != nil {
-				log.Printf("Elastic in state: %v. \n", backupInfo.Snapshots[0].State)
-				if backupInfo.Snapshots[0].State == "SUCCESS" {
-					completedBackup <- *backupInfo
-					return
-				}
+// notCompleted fails a backup that was not done while exporting was paused. One that is still running is marked
+// failed in the manifest, exporting is resumed before it completes.
+func notCompleted(name string, maxPause time.Duration) error {
+	err := fmt.Errorf("%s backup %d not completed within the maximum pause of %s", name, backupID, maxPause)
+	if entry := record.Component(name); entry != nil && entry.State == component.StateInProgress {
+		failComponent(name, err.Error())
+	}
+	return err
+}
 
-			}
+// failComponent marks the backup of the component failed in the manifest.
+func failComponent(name, reason string) {
+	log.Printf("❌ %s backup %d failed: %s\n", name, backupID, reason)
+	if entry := record.Component(name); entry != nil {
+		entry.State = component.StateFailed
+		entry.Reason = reason
+	}
+}
 
-			log.Println("waiting elastic", pollInterval)
-			time.Sleep(pollInterval)
+// takeBackup requests the backup of the component and waits for it at most wait. It reports whether the backup
+// completed, a backup that is not done in time is recorded as in progress. It returns an error if the backup can't be
+// requested or ctx is cancelled.
+func takeBackup(ctx context.Context, c component.Component, wait time.Duration) (bool, error) {
+	start := time.Now()
+	err := c.Request(ctx, backupID)
+	if errors.Is(err, component.ErrAlreadyExists) {
+		// A retried run, wait for the backup that is already there
+		log.Printf("%s backup %d already exists\n", c.Name(), backupID)
+		err = nil
+	}
+	if err != nil {
+		recordComponent(c, component.Status{ID: backupID, State: component.StateFailed, Reason: err.Error()}, start)
+		return false, fmt.Errorf("request failed: %w", err)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, wait)
+	defer cancel()
+	select {
+	case res := <-pollUntilDone(waitCtx, c):
+		log.Printf("✅ %s Done! %s %s\n", c.Name(), res.State, res.Reason)
+		recordComponent(c, res, start)
+		return res.State == component.StateCompleted, nil
+	case <-waitCtx.Done():
+		if ctx.Err() != nil {
+			log.Printf("%s interrupted\n", c.Name())
+			recordComponent(c, component.Status{ID: backupID, State: component.StateInProgress, Reason: "interrupted"}, start)
+			return false, ctx.Err()
 		}
-	}()
+		log.Printf("%s timed out\n", c.Name())
+		recordComponent(c, component.Status{ID: backupID, State: component.StateInProgress, Reason: "timed out"}, start)
+		return false, nil
+	}
+}
 
-	return completedBackup
+func recordComponent(c component.Component, status component.Status, start time.Time) {
+	record.Components = append(record.Components, manifest.Component{
+		Name:      c.Name(),
+		State:     status.State,
+		Reason:    status.Reason,
+		Snapshots: status.Snapshots,
+		Duration:  time.Since(start).Round(time.Second).String(),
+	})
 }
 
-func waitUntilZeebeBackupCompleted(ctx context.Context, client *zeebeBackup.BackupClient) <-chan zeebeBackup.BackupResponse {
-	completedBackup := make(chan zeebeBackup.BackupResponse, 1)
+// pollUntilDone polls the state of the backup until it is done or ctx is cancelled.
+func pollUntilDone(ctx context.Context, c component.Component) <-chan component.Status {
+	doneBackup := make(chan component.Status, 1)
 	go func() {
 		for {
-			backupInfo, err := client.GetBackup(ctx, backupID)
+			status, err := c.Status(ctx, backupID)
 			if err != nil {
 				log.Println(err)
-				continue
-			}
-			if backupInfo != nil {
-				log.Printf("%s Backup in state: %s \n", "zeebe", backupInfo.State)
-				if backupInfo.State == "COMPLETED" {
-					completedBackup <- *backupInfo
+			} else {
+				log.Printf("%s Backup in state: %s \n", c.Name(), status.State)
+				if status.State.Done() {
+					doneBackup <- *status
 					return
 				}
-
 			}
-			log.Println("sleeping zeebe", pollInterval)
-			time.Sleep(pollInterval)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(pollInterval):
+			}
 		}
 	}()
 
-	return completedBackup
+	return doneBackup
 }
@@ -1,6 +1,11 @@
 package runner
 
-import "time"
+import (
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+	"k8s.io/client-go/kubernetes"
+)
 
 type BackupDefinitionBuilder struct {
 	backupDefinition BackupDefinition
@@ -37,8 +42,48 @@ func (b BackupDefinitionBuilder) Elastic(url, snapshotRepositoryName string) Bac
 	return b
 }
 
+// Components are backed up after Zeebe, e.g. the database of Identity's Keycloak or the secrets of Connectors.
+func (b BackupDefinitionBuilder) Components(components []component.Component) BackupDefinitionBuilder {
+	b.backupDefinition.components = components
+	return b
+}
+
+// Kube is where the manifest of the backup is written to.
+func (b BackupDefinitionBuilder) Kube(kubeClient kubernetes.Interface, namespace string) BackupDefinitionBuilder {
+	b.backupDefinition.kubeClient = kubeClient
+	b.backupDefinition.namespace = namespace
+	return b
+}
+
+// ZeebeStatefulSet is the broker StatefulSet the cluster size is recorded from, detected if empty.
+func (b BackupDefinitionBuilder) ZeebeStatefulSet(name string) BackupDefinitionBuilder {
+	b.backupDefinition.zeebeStatefulSet = name
+	return b
+}
+
+// IgnoreVersionCheck takes the backup even if the versions of the components don't fit together.
+func (b BackupDefinitionBuilder) IgnoreVersionCheck(ignore bool) BackupDefinitionBuilder {
+	b.backupDefinition.ignoreVersionCheck = ignore
+	return b
+}
+
+// MaxExportPause is how long exporting may stay paused before the watchdog resumes it and fails the backup.
+func (b BackupDefinitionBuilder) MaxExportPause(maxPause time.Duration) BackupDefinitionBuilder {
+	b.backupDefinition.maxExportPause = maxPause
+	return b
+}
+
+// HardPause pauses exporting hard even if the brokers support a soft pause.
+func (b BackupDefinitionBuilder) HardPause(hard bool) BackupDefinitionBuilder {
+	b.backupDefinition.hardPause = hard
+	return b
+}
+
 func (b BackupDefinitionBuilder) Build() BackupDefinition {
 	b.backupDefinition.backupID = time.Now().Unix()
+	if b.backupDefinition.maxExportPause == 0 {
+		b.backupDefinition.maxExportPause = DefaultMaxExportPause
+	}
 	if b.backupDefinition.zeebeIndexPrefix == "" {
 		b.backupDefinition.zeebeIndexPrefix = "zeebe-record*"
 	}
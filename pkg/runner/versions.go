@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"c8backup/pkg/backup-client/component"
+	"c8backup/pkg/backup-client/elastic"
+	"c8backup/pkg/backup-client/zeebe"
+	"c8backup/pkg/compat"
+	"c8backup/pkg/manifest"
+	"c8backup/pkg/restore"
+)
+
+// versioned is a component that tells its version.
+type versioned interface {
+	Name() string
+	Version(ctx context.Context) (string, error)
+}
+
+// probeVersions probes the versions of the webapps and the brokers. Unknown versions are left empty and not checked.
+func probeVersions(ctx context.Context, definition BackupDefinition) map[string]string {
+	var apps []versioned
+	for _, app := range definition.webapps() {
+		apps = append(apps, app)
+	}
+	if definition.zeebeURL != "" {
+		apps = append(apps, zeebeBackup.NewZeebeClient(definition.zeebeURL))
+	}
+	versions := map[string]string{}
+	for _, app := range apps {
+		version, err := app.Version(ctx)
+		if err != nil {
+			log.Printf("unable to get the version of %s: %v\n", app.Name(), err)
+		} else {
+			log.Printf("%s version %s\n", app.Name(), version)
+		}
+		versions[app.Name()] = version
+	}
+	return versions
+}
+
+// recordZeebe adds the partitions of the Zeebe backup and the cluster size to the manifest and returns the version of
+// the brokers that took it, empty if unknown.
+func recordZeebe(ctx context.Context, definition BackupDefinition, zeebe *zeebeBackup.BackupClient) string {
+	backup, err := zeebe.GetBackup(ctx, backupID)
+	if err != nil || backup == nil || len(backup.Details) == 0 {
+		log.Println("unable to get the partitions of the zeebe backup", err)
+		return ""
+	}
+	if record.Zeebe == nil {
+		record.Zeebe = &manifest.Zeebe{}
+	}
+	if definition.kubeClient != nil {
+		record.Zeebe.ClusterSize, err = restore.ZeebeClusterSize(ctx, definition.kubeClient, definition.namespace, definition.zeebeStatefulSet)
+		if err != nil {
+			log.Println("unable to get the cluster size of zeebe, a restore doesn't check it:", err)
+		}
+	}
+	for _, detail := range backup.Details {
+		record.Zeebe.Partitions = append(record.Zeebe.Partitions, manifest.Partition{
+			ID:                 detail.PartitionId,
+			CheckpointPosition: detail.CheckpointPosition,
+			CreatedAt:          detail.CreatedAt,
+			SnapshotID:         detail.SnapshotId,
+			BrokerVersion:      detail.BrokerVersion,
+		})
+	}
+	return backup.Details[0].BrokerVersion
+}
+
+// checkVersions fails the backup if the versions are not compatible, unless the check is ignored.
+func checkVersions(definition BackupDefinition, versions map[string]string) error {
+	platform, warnings, err := compat.CheckConsistent(versions)
+	for _, warning := range warnings {
+		log.Println("⚠️", warning)
+	}
+	if err == nil {
+		if platform != "" {
+			log.Println("camunda version", platform)
+		}
+		return nil
+	}
+	if definition.ignoreVersionCheck {
+		log.Println("ignoring the version check:", err)
+		return nil
+	}
+	return fmt.Errorf("%w - pass --ignore-version-check to back up anyway", err)
+}
+
+// writeManifest adds the indices of the snapshots and signs and writes the manifest, if the backup runs with a
+// Kubernetes client.
+func writeManifest(ctx context.Context, definition BackupDefinition) {
+	if definition.kubeClient == nil {
+		return
+	}
+	record.Duration = time.Since(record.CreatedAt).Round(time.Second).String()
+	refreshUnfinished(ctx, definition)
+	if definition.elasticURL != "" {
+		elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
+		for i, entry := range record.Components {
+			if len(entry.Snapshots) == 0 {
+				continue
+			}
+			snapshots, err := elasticClient.GetSnapshots(ctx, entry.Snapshots)
+			if err != nil {
+				log.Printf("unable to get the indices of the %s snapshots: %v\n", entry.Name, err)
+				continue
+			}
+			for _, snapshot := range snapshots.Snapshots {
+				record.Components[i].Indices = append(record.Components[i].Indices, snapshot.Indices...)
+			}
+		}
+	}
+	err := manifest.Write(ctx, definition.kubeClient, definition.namespace, record)
+	if err != nil {
+		log.Println("unable to write the manifest of the backup", err)
+		return
+	}
+	log.Println("manifest of the backup written to namespace", definition.namespace)
+}
+
+// refreshUnfinished asks the components whose backup was not done when the run stopped waiting for their current
+// state, so that the manifest doesn't keep a backup that completed meanwhile as in progress.
+func refreshUnfinished(ctx context.Context, definition BackupDefinition) {
+	var clients []component.Component
+	for _, app := range definition.webapps() {
+		clients = append(clients, app)
+	}
+	if definition.zeebeURL != "" {
+		clients = append(clients, zeebeBackup.NewZeebeClient(definition.zeebeURL))
+	}
+	if definition.elasticURL != "" {
+		elasticClient := elastic.NewElasticClient(definition.elasticURL, definition.backupRepositoryName)
+		clients = append(clients, elastic.NewZeebeRecords(elasticClient, definition.zeebeIndexPrefix))
+	}
+	clients = append(clients, definition.components...)
+	for _, c := range clients {
+		entry := record.Component(c.Name())
+		if entry == nil || entry.State.Done() {
+			continue
+		}
+		status, err := c.Status(ctx, backupID)
+		if err != nil {
+			log.Printf("unable to get the state of the %s backup: %v\n", c.Name(), err)
+			continue
+		}
+		entry.State = status.State
+		entry.Snapshots = status.Snapshots
+		if status.Reason != "" {
+			entry.Reason = status.Reason
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"c8backup/pkg/backup-client/zeebe"
+)
+
+// DefaultMaxExportPause is how long exporting may stay paused for the Zeebe backup. The brokers keep the log until
+// it is exported, so a pause that never ends fills their disks.
+const DefaultMaxExportPause = 5 * time.Minute
+
+const resumeAttempts = 5
+
+// exportWatchdog tracks the pause of exporting and resumes it once the pause exceeded maxPause, whether the backup
+// finished or not. A backup that is interrupted meanwhile, e.g. by the deadline of its Job, resumes exporting before
+// it exits.
+type exportWatchdog struct {
+	zeebe    *zeebeBackup.BackupClient
+	maxPause time.Duration
+	pausedAt time.Time
+	done     chan struct{}
+	stopped  sync.WaitGroup
+	mu       sync.Mutex
+	forced   bool
+	resumed  bool
+}
+
+func startExportWatchdog(zeebe *zeebeBackup.BackupClient, maxPause time.Duration) *exportWatchdog {
+	w := &exportWatchdog{zeebe: zeebe, maxPause: maxPause, pausedAt: time.Now(), done: make(chan struct{})}
+	w.stopped.Add(1)
+	go w.watch()
+	return w
+}
+
+// watch resumes exporting at the deadline. It resumes with its own context, the one of the backup may be cancelled.
+func (w *exportWatchdog) watch() {
+	defer w.stopped.Done()
+	deadline := time.NewTimer(w.maxPause)
+	defer deadline.Stop()
+	ticker := time.NewTicker(pollInterval * 6)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+			log.Printf("⏸️ zeebe exporting paused for %s of at most %s\n", time.Since(w.pausedAt).Round(time.Second), w.maxPause)
+		case <-deadline.C:
+			log.Printf("🚨 zeebe exporting paused for more than %s, resuming it, the backup fails\n", w.maxPause)
+			w.mu.Lock()
+			w.forced = true
+			w.mu.Unlock()
+			w.resume(context.Background())
+			return
+		}
+	}
+}
+
+// forcedResume reports whether the watchdog resumed exporting before the backup did.
+func (w *exportWatchdog) forcedResume() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.forced
+}
+
+// remaining is how long exporting may stay paused yet.
+func (w *exportWatchdog) remaining() time.Duration {
+	return time.Until(w.pausedAt.Add(w.maxPause))
+}
+
+// stop ends the watch and resumes exporting, unless the watchdog already did. It fails the backup if exporting
+// can't be resumed.
+func (w *exportWatchdog) stop() error {
+	close(w.done)
+	w.stopped.Wait()
+	w.resume(context.Background())
+	if !w.resumed {
+		return errors.New("unable to resume zeebe exporting")
+	}
+	return nil
+}
+
+// resume retries resuming exporting and alerts loudly if it stays paused.
+func (w *exportWatchdog) resume(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.resumed {
+		return
+	}
+	var err error
+	for attempt := 1; attempt <= resumeAttempts; attempt++ {
+		err = w.zeebe.ResumeExporting(ctx)
+		if err == nil {
+			w.resumed = true
+			log.Printf("▶️▶️▶️ZEEBE EXPORT RESUMED after %s▶️▶️▶️\n", time.Since(w.pausedAt).Round(time.Second))
+			warnIfStillPaused(ctx, w.zeebe)
+			return
+		}
+		log.Printf("resuming zeebe exporting failed (attempt %d of %d): %v\n", attempt, resumeAttempts, err)
+		time.Sleep(pollInterval)
+	}
+	alertPaused(err)
+}
+
+// warnIfStillPaused checks the partitions of the broker behind the endpoint after a resume. Brokers that don't report
+// their exporter phase are not checked.
+func warnIfStillPaused(ctx context.Context, zeebe *zeebeBackup.BackupClient) {
+	partitions, err := zeebe.Partitions(ctx)
+	if err != nil {
+		return
+	}
+	for id, partition := range partitions {
+		if partition.ExporterPhase == zeebeBackup.ExporterPhasePaused || partition.ExporterPhase == zeebeBackup.ExporterPhaseSoftPaused {
+			alertPaused(nil)
+			log.Printf("🚨 partition %d still reports exporter phase %s\n", id, partition.ExporterPhase)
+			return
+		}
+	}
+}
+
+func alertPaused(err error) {
+	log.Println("🚨🚨🚨 ZEEBE EXPORTING IS STILL PAUSED 🚨🚨🚨", err)
+	log.Println("🚨 the disks of the brokers fill up until it is resumed, run: c8backup zeebe exporting resume --zeebe <mgmt endpoint>")
+}